@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+	"github.com/gmsakibursabbir/tinitui/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	thresholdFlag    string
+	checkFormatFlag  string
+	checkHistoryFlag string
+	unknownFlag      string
+)
+
+// Values of check --format.
+const (
+	checkText   = "text"
+	checkGitHub = "github"
+)
+
+// Values of check --unknown, for images whose savings can't be estimated.
+const (
+	unknownFail = "fail"
+	unknownWarn = "warn"
+)
+
+// finding is an image that hasn't been compressed, or changed since.
+type finding struct {
+	Path     string
+	State    string // history.FileNew or history.FileChanged
+	Size     int64
+	Estimate int64 // Bytes compression would likely save, -1 if unknown
+	Over     bool  // Fails the check, see over
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check [paths...]",
+	Short: "Report images that haven't been compressed",
+	Long: `Report images that haven't been compressed, without uploading anything.
+
+An image passes when history records it as the output of a compression, or
+as an original compressed to a separate file, by content hash. Images never
+compressed, or changed since, are listed with the savings estimated from
+past compressions of the same format.
+
+The exit code is 1 when the estimated savings of an image exceed --threshold,
+2 on bad flags and 0 otherwise. Images whose savings can't be estimated, for
+lack of past compressions, fail as well unless --unknown is warn. With
+--format github each image becomes a workflow annotation: an error when it
+fails, a warning otherwise, with paths relative to $GITHUB_WORKSPACE.
+
+CI runners start without history. Commit one written with
+"tinitui history --ndjson <file>" and pass it with --history to check
+against it instead.`,
+	Example: `  tinitui check
+  tinitui check assets --threshold 100KB
+  tinitui history --ndjson .tinitui-history.jsonl
+  tinitui check --history .tinitui-history.jsonl --git-changed origin/main --format github`,
+	Run: func(cmd *cobra.Command, args []string) {
+		paths := args
+		if len(paths) == 0 {
+			paths = []string{"."}
+		}
+		if checkFormatFlag != checkText && checkFormatFlag != checkGitHub {
+			fatalf(exitConfig, "Error: --format must be %s or %s", checkText, checkGitHub)
+		}
+		if unknownFlag != unknownFail && unknownFlag != unknownWarn {
+			fatalf(exitConfig, "Error: --unknown must be %s or %s", unknownFail, unknownWarn)
+		}
+		threshold, err := parseSize(thresholdFlag)
+		if err != nil {
+			fatalf(exitConfig, "Error: --threshold: %v", err)
+		}
+		filters, err := scanFilters()
+		if err != nil {
+			fatalf(exitConfig, "Error: %v", err)
+		}
+
+		scanRes, err := scanner.ScanWithOptions(paths, scanner.Options{
+			Recursive:  true,
+			Exclude:    excludeFlag,
+			Include:    includeFlag,
+			Gitignore:  gitignoreFlag,
+			Filters:    filters,
+			Ignore:     cfg.Ignore,
+			IgnoreBase: cfg.ProjectDir(),
+			MaxDepth:   maxDepthFlag,
+			SkipHidden: skipHiddenFlag,
+		})
+		if err != nil {
+			fatalf(exitConfig, "Scan error: %v", err)
+		}
+		for _, e := range scanRes.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", e)
+		}
+		images := scanRes.Images
+		sort.Strings(images)
+
+		records, err := checkRecords()
+		if err != nil {
+			fatalf(exitConfig, "Error loading history: %v", err)
+		}
+		known := history.NewKnown(records)
+		ratios := history.Ratios(records)
+
+		var findings []*finding
+		for _, path := range images {
+			f, err := checkImage(known, ratios, path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			if f == nil {
+				continue
+			}
+			f.Over = f.over(threshold, unknownFlag)
+			findings = append(findings, f)
+		}
+		if len(findings) > 0 && len(ratios) == 0 {
+			fmt.Fprintln(os.Stderr, "Warning: no compressions in history to estimate savings from; see --history and --unknown")
+		}
+
+		if checkFormatFlag == checkGitHub {
+			printAnnotations(os.Stdout, findings)
+		} else {
+			printFindings(os.Stdout, len(images), findings)
+		}
+		for _, f := range findings {
+			if f.Over {
+				os.Exit(exitFailures)
+			}
+		}
+	},
+}
+
+// checkRecords returns the records of --history, or those of the local
+// history including archived ones.
+func checkRecords() ([]*history.Record, error) {
+	if checkHistoryFlag != "" {
+		return history.ReadLog(checkHistoryFlag)
+	}
+	hMgr, err := history.New()
+	if err != nil {
+		return nil, err
+	}
+	archived, err := hMgr.Archived()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: reading archived history: %v\n", err)
+	}
+	return append(hMgr.All(), archived...), nil
+}
+
+// checkImage returns a finding for the image at path, or nil when history
+// records it as compressed.
+func checkImage(known *history.Known, ratios map[string]float64, path string) (*finding, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, err
+	}
+
+	state := known.State(path, hex.EncodeToString(h.Sum(nil)), info.ModTime())
+	if state == history.FileCompressed {
+		return nil, nil
+	}
+	f := &finding{Path: path, State: state, Size: info.Size(), Estimate: -1}
+	ratio, ok := ratios[history.FormatOf(path)]
+	if !ok {
+		ratio, ok = ratios["*"]
+	}
+	if ok {
+		f.Estimate = int64(float64(f.Size) * (1 - ratio))
+	}
+	return f, nil
+}
+
+// over reports whether f fails the check: its estimated savings exceed
+// threshold, or can't be estimated and unknown is unknownFail.
+func (f *finding) over(threshold int64, unknown string) bool {
+	if f.Estimate < 0 {
+		return unknown == unknownFail
+	}
+	return f.Estimate > threshold
+}
+
+func (f *finding) estimate() string {
+	if f.Estimate < 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s (%.0f%%)", formatBytes(f.Estimate), float64(f.Estimate)/float64(max(f.Size, 1))*100)
+}
+
+func printFindings(w io.Writer, checked int, findings []*finding) {
+	var total int64
+	over := 0
+	if len(findings) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "State\tFile\tSize\tEst. savings\t")
+		for _, f := range findings {
+			mark := ""
+			if f.Over {
+				mark = "over threshold"
+				if f.Estimate < 0 {
+					mark = "no estimate"
+				}
+				over++
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.State, f.Path, formatBytes(f.Size), f.estimate(), mark)
+			total += max(f.Estimate, 0)
+		}
+		tw.Flush()
+	}
+	fmt.Fprintln(w, "--------------------------------------------------")
+	fmt.Fprintf(w, "Images checked    : %d\n", checked)
+	fmt.Fprintf(w, "Not compressed    : %d\n", len(findings))
+	fmt.Fprintf(w, "Failing           : %d\n", over)
+	fmt.Fprintf(w, "Estimated savings : %s\n", formatBytes(total))
+}
+
+// printAnnotations writes a GitHub Actions workflow command per finding.
+// Annotations only attach to files given relative to the workspace.
+func printAnnotations(w io.Writer, findings []*finding) {
+	base := os.Getenv("GITHUB_WORKSPACE")
+	if base == "" {
+		base, _ = os.Getwd()
+	}
+	for _, f := range findings {
+		level := "warning"
+		if f.Over {
+			level = "error"
+		}
+		what := "is not compressed"
+		if f.State == history.FileChanged {
+			what = "changed since it was compressed"
+		}
+		path := workspacePath(base, f.Path)
+		msg := fmt.Sprintf("%s %s: %s, estimated savings %s. Run 'tinitui compress %s'.",
+			path, what, formatBytes(f.Size), f.estimate(), path)
+		fmt.Fprintf(w, "::%s file=%s,title=Unoptimised image::%s\n",
+			level, escapeProperty(filepath.ToSlash(path)), escapeData(msg))
+	}
+}
+
+// workspacePath returns path relative to base, or unchanged when it lies
+// outside of it.
+func workspacePath(base, path string) string {
+	if base == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	f := checkCmd.Flags()
+	f.StringVar(&thresholdFlag, "threshold", "0", "Fail when an image could save more than this (e.g. 100KB)")
+	f.StringVar(&checkFormatFlag, "format", checkText, "Output format: text or github (workflow annotations)")
+	f.StringVar(&unknownFlag, "unknown", unknownFail, "Images whose savings can't be estimated: fail or warn")
+	f.StringVar(&checkHistoryFlag, "history", "", "Check against this history file (from 'history --ndjson') instead of the local history")
+	f.StringSliceVar(&excludeFlag, "exclude", nil, "Skip paths matching a gitignore-style pattern (repeatable)")
+	f.StringSliceVar(&includeFlag, "include", nil, "Only check files matching a gitignore-style pattern (repeatable)")
+	f.BoolVar(&gitignoreFlag, "gitignore", false, "Also skip files ignored by .gitignore")
+	f.StringVar(&largerThanFlag, "larger-than", "", "Only files larger than this size (e.g. 500KB, 2MB)")
+	f.StringVar(&gitChangedFlag, "git-changed", "", "Only files changed on this branch versus a git ref (e.g. origin/main)")
+	f.BoolVar(&gitStagedFlag, "git-staged", false, "Only files staged in git")
+	f.IntVar(&maxDepthFlag, "max-depth", 0, "Directory levels to descend into (0 = unlimited, 1 = only the given directories)")
+	f.BoolVar(&skipHiddenFlag, "skip-hidden", false, "Skip hidden files and directories")
+}
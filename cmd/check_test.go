@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+)
+
+func TestCheckWithoutHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logo.png")
+	if err := os.WriteFile(path, make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	other := &history.Record{File: "/img/a.png", Status: history.StatusSuccess, BeforeSize: 1000, AfterSize: 400, SavedBytes: 600}
+
+	tests := []struct {
+		name      string
+		records   []*history.Record
+		unknown   string
+		threshold int64
+		want      bool
+	}{
+		{"empty history fails", nil, unknownFail, 0, true},
+		{"empty history warns", nil, unknownWarn, 0, false},
+		{"estimate over threshold", []*history.Record{other}, unknownWarn, 1024, true},
+		{"estimate under threshold", []*history.Record{other}, unknownFail, 2048, false},
+	}
+	for _, tt := range tests {
+		f, err := checkImage(history.NewKnown(tt.records), history.Ratios(tt.records), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f == nil {
+			t.Fatalf("%s: image passed the check", tt.name)
+		}
+		if got := f.over(tt.threshold, tt.unknown); got != tt.want {
+			t.Errorf("%s: over = %v, want %v (estimate %d)", tt.name, got, tt.want, f.Estimate)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/gmsakibursabbir/tinitui/internal/config"
 	"github.com/gmsakibursabbir/tinitui/internal/history"
 	"github.com/gmsakibursabbir/tinitui/internal/pipeline"
 	"github.com/gmsakibursabbir/tinitui/internal/scanner"
@@ -18,18 +21,40 @@ var (
 	stdinFlag     bool
 	outputDirFlag string
 	suffixFlag    string
+	dryRunFlag    bool
+	onLockedFlag  string
+	excludeFlag   []string
+	includeFlag   []string
+	gitignoreFlag bool
+
+	largerThanFlag       string
+	smallerThanFlag      string
+	modifiedSinceFlag    string
+	minDimensionsFlag    string
+	maxDimensionsFlag    string
+	newerThanHistoryFlag bool
+	gitChangedFlag       string
+	gitStagedFlag        bool
+
+	maxDepthFlag   int
+	symlinksFlag   string
+	skipHiddenFlag bool
+	sortFlag       bool
+
+	profileFlag string
+	outputFlag  string
+
+	failFastFlag    bool
+	maxFailuresFlag int
 )
 
+// scanBatchSize caps how many streamed paths are queued at once.
+const scanBatchSize = 256
+
 var compressCmd = &cobra.Command{
 	Use:   "compress [paths...]",
 	Short: "Compress images via CLI",
 	Run: func(cmd *cobra.Command, args []string) {
-		// Check API Key
-		if !cfg.IsConfigured() {
-			fmt.Println("Error: API Key not configured. Run 'tinytui config set-key <KEY>' first.")
-			os.Exit(1)
-		}
-
 		paths := args
 		if stdinFlag {
 			// Read from stdin
@@ -43,30 +68,53 @@ var compressCmd = &cobra.Command{
 		}
 
 		if len(paths) == 0 {
+			if stdinFlag {
+				// An empty list, e.g. from git diff in CI
+				fatalf(exitNothingToDo, "No images found.")
+			}
 			cmd.Help()
-			return
+			os.Exit(exitConfig)
 		}
 
-		// Scan
-		scanRes, err := scanner.Scan(paths, true) // recurse by default for CLI? Prompt doesn't specify default recursion for CLI, but for UI it says "Options: [x] recursive". Let's assume true or add flag.
-		// "B) Paste Path / Glob ... ./imgs/*.png"
+		filters, err := scanFilters()
 		if err != nil {
-			fmt.Printf("Scan error: %v\n", err)
-			os.Exit(1)
+			fatalf(exitConfig, "Error: %v", err)
 		}
-		
-		if len(scanRes.Errors) > 0 {
-			for _, e := range scanRes.Errors {
-				fmt.Printf("Warning: %v\n", e)
-			}
+
+		if symlinksFlag != "" && symlinksFlag != string(scanner.SymlinksFollow) && symlinksFlag != string(scanner.SymlinksSkip) {
+			fatalf(exitConfig, "Error: --symlinks must be %q or %q", scanner.SymlinksFollow, scanner.SymlinksSkip)
 		}
-		
-		if len(scanRes.Images) == 0 {
-			fmt.Println("No images found.")
-			return
+		if err := checkOutput(outputFlag); err != nil {
+			fatalf(exitConfig, "Error: %v", err)
+		}
+		if maxFailuresFlag < 0 {
+			fatalf(exitConfig, "Error: --max-failures must not be negative")
 		}
+		maxFailures := maxFailuresFlag
+		if failFastFlag {
+			maxFailures = 1
+		}
+		scanOpts := scanner.Options{
+			Recursive:  true,
+			Exclude:    excludeFlag,
+			Include:    includeFlag,
+			Gitignore:  gitignoreFlag,
+			Filters:    filters,
+			Ignore:     cfg.Ignore,
+			IgnoreBase: cfg.ProjectDir(),
+			MaxDepth:   maxDepthFlag,
+			Symlinks:   scanner.SymlinkPolicy(symlinksFlag),
+			SkipHidden: skipHiddenFlag,
+			Sorted:     sortFlag,
+		} // recurse by default for CLI? Prompt doesn't specify default recursion for CLI, but for UI it says "Options: [x] recursive". Let's assume true or add flag.
+		// "B) Paste Path / Glob ... ./imgs/*.png"
 
 		// Override config if flags set
+		if profileFlag != "" {
+			if err := cfg.SelectProfile(profileFlag); err != nil {
+				fatalf(exitConfig, "Error: %v", err)
+			}
+		}
 		if outputDirFlag != "" {
 			cfg.OutputMode = "directory"
 			cfg.OutputDir = outputDirFlag
@@ -77,102 +125,279 @@ var compressCmd = &cobra.Command{
 			// If not set via flag, keep config default
 		}
 
+		if onLockedFlag != "" {
+			if onLockedFlag != config.OnLockedSkip && onLockedFlag != config.OnLockedWait {
+				fatalf(exitConfig, "Error: --on-locked must be %q or %q", config.OnLockedSkip, config.OnLockedWait)
+			}
+			cfg.OnLocked = onLockedFlag
+		}
+
+		if dryRunFlag {
+			// Scan everything up front; the plan lists files sorted
+			scanRes, err := scanner.ScanWithOptions(paths, scanOpts)
+			if err != nil {
+				fatalf(exitConfig, "Scan error: %v", err)
+			}
+			for _, e := range scanRes.Errors {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", e)
+			}
+			for _, w := range scanRes.Warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+			}
+			if len(scanRes.Images) == 0 {
+				fatalf(exitNothingToDo, "No images found.")
+			}
+			printPlan(scanRes.Images)
+			return
+		}
+
+		// Check API Key
+		if err := cfg.ResolveAPIKey(); err != nil {
+			fatalf(exitConfig, "Error reading API key: %v", err)
+		}
+		if !cfg.IsConfigured() {
+			fatalf(exitConfig, "Error: API Key not configured. Run 'tinitui config set-key <KEY>' first.")
+		}
+
 		// Setup Pipeline
 		p := pipeline.New(cfg, cfg.APIKey)
-		p.Configure(2) // Default concurr
+		p.Configure(cfg.Concurrency)
+		// Every outcome is recorded, best effort
+		hMgr, err := history.New()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: history disabled: %v\n", err)
+		} else {
+			hMgr.SetRetention(cfg.History)
+			p.SetHistory(hMgr, history.SourceCLI)
+		}
+		out, _ := newRunOutput(outputFlag, os.Stdout, p.Session())
+		events := p.Events()
+		started := time.Now()
 		p.Start()
-		defer p.Stop()
-
-		// Add files
-		p.AddFiles(scanRes.Images)
-
-		// Setup History Manager
-		hMgr, _ := history.New() // Ignore error, best effort logging
-
-		// Monitor Progress
-		// Table output: | Status | File | Before | After | Saved % |
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-		fmt.Fprintln(w, "Status\tFile\tBefore\tAfter\tSaved %\tError")
-		
-		totalBefore := int64(0)
-		totalAfter := int64(0)
-		totalSaved := int64(0)
-		processedCount := 0
-		errorCount := 0
-
-		// We need to track how many jobs completed to exit
-		// Pipeline doesn't auto-close Updates when empty.
-		// We know how many we added.
-		target := len(scanRes.Images)
-		done := 0
-
-		for job := range p.Updates() {
-			if job.Status == pipeline.StatusDone || job.Status == pipeline.StatusFailed {
-				done++
-				
-				statusParams := job.Status
-				errStr := ""
-				if job.Error != nil {
-					errStr = job.Error.Error()
-					errorCount++
-				} else {
-					processedCount++
-					totalBefore += job.OriginalSize
-					totalAfter += int64(job.CompressedSize) // int64? Fixed job struct type mismatch in mind? Job has int64.
-					totalSaved += job.SavedBytes
-					
-					// Log to history
-					if hMgr != nil {
-						hMgr.Add(&history.Record{
-							Timestamp:    time.Now(),
-							File:         job.FilePath,
-							BeforeSize:   job.OriginalSize,
-							AfterSize:    job.CompressedSize,
-							SavedBytes:   job.SavedBytes,
-							SavedPercent: job.SavedPercent,
-							Status:       "success",
-						})
-					}
+
+		// Scan, queueing images as they are found so compression starts
+		// while large trees are still being walked
+		ctx, cancelScan := context.WithCancel(context.Background())
+		defer cancelScan()
+		results, err := scanner.Stream(ctx, paths, scanOpts)
+		if err != nil {
+			fatalf(exitConfig, "Scan error: %v", err)
+		}
+		// Outputs of different inputs can only collide when they are renamed
+		// or gathered in one directory. Then the whole scan is queued at once
+		// in the sorted order of the dry-run plan, so the same input wins.
+		collisions := cfg.OutputDir != "" || cfg.FixExtensions
+		scanned := make(chan int, 1)
+		go func() {
+			found := 0
+			var batch []string
+			flush := func() {
+				if len(batch) > 0 {
+					sort.Strings(batch)
+					found += p.AddFiles(batch)
+					batch = nil
 				}
-				
-				// Print Row
-				// In CLI mode with many files, we probably want line-by-line output?
-				// "Display table" -> usually implies buffered or updated. But for "script friendly", line by line is better or final table.
-				// "For each file: Read ... Calculate ... Display table". implies streaming table rows.
-				// "Final summary panel" at end.
-				
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.1f%%\t%s\n",
-					string(statusParams),
-					shortPath(job.FilePath),
-					formatBytes(job.OriginalSize),
-					formatBytes(job.CompressedSize),
-					job.SavedPercent,
-					errStr,
-				)
-				w.Flush()
-
-				if done >= target {
-					break // All processed
+			}
+			for r := range results {
+				switch {
+				case r.Err != nil:
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", r.Err)
+				case r.Warning != "":
+					fmt.Fprintf(os.Stderr, "Warning: %s\n", r.Warning)
+				default:
+					batch = append(batch, r.Path)
+					if !collisions && (len(batch) >= scanBatchSize || len(results) == 0) {
+						flush()
+					}
 				}
 			}
+			flush()
+			scanned <- found
+		}()
+
+		// Monitor progress until every added job has finished, the target
+		// being known once the scan has finished, or the run is aborted
+		var sum runSummary
+		abort := exitOK
+		target := -1
+		for abort == exitOK && (target < 0 || sum.Total < target) {
+			var e pipeline.Event
+			select {
+			case target = <-scanned:
+				continue
+			case e = <-events:
+			}
+			out.event(e)
+			sum.add(&e.Job)
+			if e.Job.Status != pipeline.StatusFailed {
+				continue
+			}
+			if abort = abortCode(e.Job.Error); abort != exitOK {
+				fmt.Fprintf(os.Stderr, "Stopping: %v\n", e.Job.Error)
+			} else if maxFailures > 0 && sum.Failed >= maxFailures {
+				fmt.Fprintf(os.Stderr, "Stopping: %d images failed\n", sum.Failed)
+				abort = exitFailures
+			}
+		}
+		if abort != exitOK {
+			cancelScan()
+			p.Abort()
+		}
+		p.Stop()
+		// Jobs finishing or cancelled while aborting
+		for e := range events {
+			out.event(e)
+			sum.add(&e.Job)
+		}
+		sum.DurationMS = time.Since(started).Milliseconds()
+
+		if hMgr != nil {
+			if err := hMgr.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: saving history: %v\n", err)
+			}
+		}
+		if sum.Total > 0 {
+			if err := p.RunComplete(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
 		}
 
-		// Final Summary
-		fmt.Println("--------------------------------------------------")
-		fmt.Printf("Compression complete ✔\n")
-		fmt.Printf("Files processed : %d\n", processedCount)
-		fmt.Printf("Total before    : %s\n", formatBytes(totalBefore))
-		fmt.Printf("Total after     : %s\n", formatBytes(totalAfter))
-		fmt.Printf("Total saved     : %s (%.0f%%)\n", formatBytes(totalSaved), float64(totalSaved)/float64(totalBefore)*100)
-		fmt.Printf("Errors          : %d\n", errorCount)
+		switch {
+		case abort != exitOK:
+			sum.ExitCode = abort
+		case sum.Failed > 0:
+			sum.ExitCode = exitFailures
+		case sum.Done == 0:
+			sum.ExitCode = exitNothingToDo
+		}
+		out.finish(sum)
+		os.Exit(sum.ExitCode)
 	},
 }
 
+// scanFilters builds the scanner filters from the command line flags.
+func scanFilters() (scanner.FilterOptions, error) {
+	var f scanner.FilterOptions
+	var err error
+	if largerThanFlag != "" {
+		if f.LargerThan, err = parseSize(largerThanFlag); err != nil {
+			return f, fmt.Errorf("--larger-than: %w", err)
+		}
+	}
+	if smallerThanFlag != "" {
+		if f.SmallerThan, err = parseSize(smallerThanFlag); err != nil {
+			return f, fmt.Errorf("--smaller-than: %w", err)
+		}
+	}
+	if minDimensionsFlag != "" {
+		if f.MinWidth, f.MinHeight, err = parseDimensions(minDimensionsFlag); err != nil {
+			return f, fmt.Errorf("--min-dimensions: %w", err)
+		}
+	}
+	if maxDimensionsFlag != "" {
+		if f.MaxWidth, f.MaxHeight, err = parseDimensions(maxDimensionsFlag); err != nil {
+			return f, fmt.Errorf("--max-dimensions: %w", err)
+		}
+	}
+	if modifiedSinceFlag != "" {
+		if f.ModifiedSince, err = parseTime(modifiedSinceFlag); err != nil {
+			return f, fmt.Errorf("--modified-since: %w", err)
+		}
+	}
+	if newerThanHistoryFlag {
+		hMgr, err := history.New()
+		if err != nil {
+			return f, fmt.Errorf("--newer-than-history: %w", err)
+		}
+		f.LastCompressed = hMgr.LastCompressed()
+	}
+	f.GitChanged = gitChangedFlag
+	f.GitStaged = gitStagedFlag
+	return f, nil
+}
+
+// printPlan shows what compressing images would do without uploading anything.
+func printPlan(images []string) {
+	var ratios map[string]float64
+	if hMgr, err := history.New(); err == nil {
+		ratios = hMgr.CompressionRatios()
+	}
+	plan := pipeline.BuildPlan(cfg, images, ratios)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "Input\t\tOutput\tSize\tEst. After\tNote")
+	for _, e := range plan.Entries {
+		if e.SkipReason != "" {
+			continue
+		}
+		est := "?"
+		if e.EstimatedSize >= 0 {
+			est = formatBytes(e.EstimatedSize)
+		}
+		note := ""
+		if e.Overwrites {
+			note = "overwrites existing file"
+		} else if e.Output == e.Input {
+			note = "replaces original"
+		}
+		if e.Animated {
+			note = strings.TrimPrefix(note+", animated", ", ")
+		}
+		if e.Warning != "" {
+			note = strings.TrimPrefix(note+", "+e.Warning, ", ")
+		}
+		fmt.Fprintf(w, "%s\t→\t%s\t%s\t%s\t%s\n", e.Input, e.Output, formatBytes(e.Size), est, note)
+	}
+	w.Flush()
+
+	if skipped := plan.Skipped(); len(skipped) > 0 {
+		fmt.Println()
+		fmt.Println("Would skip:")
+		for _, e := range skipped {
+			fmt.Printf("  %s: %s\n", e.Input, e.SkipReason)
+		}
+	}
+
+	fmt.Println("--------------------------------------------------")
+	fmt.Printf("Dry run, nothing uploaded\n")
+	fmt.Printf("Files to compress : %d\n", plan.QuotaCost)
+	fmt.Printf("Files skipped     : %d\n", len(plan.Skipped()))
+	fmt.Printf("Quota cost        : %d compressions\n", plan.QuotaCost)
+	fmt.Printf("Total size        : %s\n", formatBytes(plan.TotalSize))
+	if plan.Estimated == 0 {
+		fmt.Printf("Estimated savings : unknown (no history yet)\n")
+		return
+	}
+	saved, pct := plan.EstimatedSavings()
+	fmt.Printf("Estimated savings : %s (%.0f%%, based on %d/%d files)\n", formatBytes(saved), pct, plan.Estimated, plan.QuotaCost)
+}
+
 func init() {
 	rootCmd.AddCommand(compressCmd)
 	compressCmd.Flags().BoolVar(&stdinFlag, "stdin", false, "Read paths from stdin")
+	compressCmd.Flags().StringVar(&profileFlag, "profile", "", "Use a named settings profile from the config")
 	compressCmd.Flags().StringVar(&outputDirFlag, "output-dir", "", "Output directory")
 	compressCmd.Flags().StringVar(&suffixFlag, "suffix", "", "Filename suffix")
+	compressCmd.Flags().StringSliceVar(&excludeFlag, "exclude", nil, "Skip paths matching a gitignore-style pattern (repeatable)")
+	compressCmd.Flags().StringSliceVar(&includeFlag, "include", nil, "Only compress files matching a gitignore-style pattern (repeatable)")
+	compressCmd.Flags().BoolVar(&gitignoreFlag, "gitignore", false, "Also skip files ignored by .gitignore")
+	compressCmd.Flags().StringVar(&largerThanFlag, "larger-than", "", "Only files larger than this size (e.g. 500KB, 2MB)")
+	compressCmd.Flags().StringVar(&smallerThanFlag, "smaller-than", "", "Only files smaller than this size")
+	compressCmd.Flags().StringVar(&minDimensionsFlag, "min-dimensions", "", "Only images at least this many pixels wide and high (e.g. 800x600, 0 = any)")
+	compressCmd.Flags().StringVar(&maxDimensionsFlag, "max-dimensions", "", "Only images at most this many pixels wide and high (e.g. 4000x4000, 0 = any)")
+	compressCmd.Flags().StringVar(&modifiedSinceFlag, "modified-since", "", "Only files modified after a date (2024-01-31) or within an age (7d, 12h)")
+	compressCmd.Flags().BoolVar(&newerThanHistoryFlag, "newer-than-history", false, "Skip files not modified since they were last compressed")
+	compressCmd.Flags().StringVar(&gitChangedFlag, "git-changed", "", "Only files changed on this branch versus a git ref (e.g. origin/main)")
+	compressCmd.Flags().BoolVar(&gitStagedFlag, "git-staged", false, "Only files staged in git")
+	compressCmd.Flags().IntVar(&maxDepthFlag, "max-depth", 0, "Directory levels to descend into (0 = unlimited, 1 = only the given directories)")
+	compressCmd.Flags().StringVar(&symlinksFlag, "symlinks", "", "Symbolic links to directories: follow or skip (default: keep linked files only)")
+	compressCmd.Flags().BoolVar(&skipHiddenFlag, "skip-hidden", false, "Skip hidden files and directories")
+	compressCmd.Flags().BoolVar(&sortFlag, "sort", false, "Process files in a deterministic order (scans one directory at a time)")
+	compressCmd.Flags().StringVar(&onLockedFlag, "on-locked", "", "When another tinitui process is compressing a file: skip or wait")
+	compressCmd.Flags().StringVar(&outputFlag, "output", outputTable, "Output format: table, json, ndjson (one event per job status change) or quiet")
+	compressCmd.Flags().BoolVar(&failFastFlag, "fail-fast", false, "Stop at the first failed image")
+	compressCmd.Flags().IntVar(&maxFailuresFlag, "max-failures", 0, "Stop after this many failed images (0 = never)")
+	compressCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Show what would be compressed without uploading")
 }
 
 func shortPath(p string) string {
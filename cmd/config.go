@@ -1,16 +1,35 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/gmsakibursabbir/tinitui/internal/config"
+	"github.com/gmsakibursabbir/tinitui/internal/credentials"
+	"github.com/gmsakibursabbir/tinitui/internal/tinify"
 	"github.com/spf13/cobra"
 )
 
+var (
+	noVerifyFlag   bool
+	showOriginFlag bool
+	migrateToFlag  string
+)
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage configuration",
+	Long: `Manage configuration.
+
+get and list show the effective settings, including project files,
+profiles and TINITUI_* environment variables. set, unset and edit change
+the global config file. Nested settings use dots, e.g. hooks.timeout_seconds.`,
 }
 
 var setKeyCmd = &cobra.Command{
@@ -18,21 +37,22 @@ var setKeyCmd = &cobra.Command{
 	Short: "Set TinyPNG API Key",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		key := args[0]
-		// Validate?
-		// User requirement 3 says check on first launch or setup.
-		// "Automatically test the key"
-		// We'll trust the input for CLI set-key for now, or just save it.
-		// "Prompt user to paste ... Automatically test the key ... Save config on success"
-		// This likely refers to TUI setup.
-		// For CLI `config set-key`, testing it is good practice.
-		
-		// Load existing or default
-		if cfg == nil {
-			cfg = config.DefaultConfig()
-		}
-		cfg.APIKey = key
-		if err := cfg.Save(); err != nil {
+		key := strings.TrimSpace(args[0])
+
+		if !noVerifyFlag {
+			fmt.Println("Verifying API key...")
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			err := tinify.NewClient(key).ValidateKey(ctx)
+			cancel()
+			if err != nil {
+				fmt.Printf("Error: API key rejected: %v\n(use --no-verify to save it anyway)\n", err)
+				os.Exit(1)
+			}
+		}
+
+		global := loadGlobal()
+		global.APIKey = key
+		if err := global.Save(); err != nil {
 			fmt.Printf("Error saving config: %v\n", err)
 			os.Exit(1)
 		}
@@ -40,7 +60,271 @@ var setKeyCmd = &cobra.Command{
 	},
 }
 
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		requireConfig()
+		if args[0] == "api_key" {
+			if err := cfg.ResolveAPIKey(); err != nil {
+				fmt.Printf("Error reading API key: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		value, err := cfg.Get(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(value)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in the global config",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		global := loadGlobal()
+		if err := global.Set(args[0], args[1]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveGlobal(global)
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Reset a setting in the global config to its default",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		global := loadGlobal()
+		if err := global.Unset(args[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveGlobal(global)
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings with their effective values",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireConfig()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, key := range config.Keys() {
+			value, _ := cfg.Get(key)
+			if key == "api_key" && len(value) > 4 {
+				value = "..." + value[len(value)-4:]
+			}
+			if showOriginFlag {
+				fmt.Fprintf(w, "%s\t%s = %s\n", originOf(key), key, value)
+			} else {
+				fmt.Fprintf(w, "%s = %s\n", key, value)
+			}
+		}
+		w.Flush()
+	},
+}
+
+// originOf describes where the effective value of a setting comes from.
+func originOf(key string) string {
+	switch src := cfg.Origin(key); src {
+	case config.SourceGlobal:
+		return "global:" + cfg.Path()
+	case config.SourceProject:
+		return "project:" + cfg.ProjectPath()
+	case config.SourceProfile:
+		return "profile:" + cfg.Profile
+	case config.SourceEnv:
+		if _, ok := os.LookupEnv(config.EnvName(key)); !ok && key == "api_key" {
+			return "env:" + config.EnvAPIKey
+		}
+		return "env:" + config.EnvName(key)
+	default:
+		return string(src)
+	}
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the location of the global config file",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := config.GlobalPath()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the global config in $EDITOR and validate it afterwards",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := config.GlobalPath()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// Start from the defaults so every setting is visible
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := config.DefaultConfig().Save(); err != nil {
+				fmt.Printf("Error creating config: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		editor := editorCommand()
+		c := exec.Command(editor[0], append(editor[1:], path)...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := c.Run(); err != nil {
+			fmt.Printf("Error running %s: %v\n", editor[0], err)
+			os.Exit(1)
+		}
+
+		global, err := config.LoadGlobal()
+		if err == nil {
+			err = global.Validate()
+		}
+		if err != nil {
+			fmt.Printf("%s is invalid:\n%v\n", path, err)
+			os.Exit(1)
+		}
+		for _, w := range global.Warnings() {
+			fmt.Printf("Warning: %s\n", w)
+		}
+		fmt.Println("Config is valid.")
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the global and project config for errors",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if cfgErr != nil {
+			fmt.Printf("Invalid config: %v\n", cfgErr)
+			os.Exit(1)
+		}
+		if err := cfg.Validate(); err != nil {
+			fmt.Printf("Invalid config:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Config is valid.")
+	},
+}
+
+var configMigrateKeyCmd = &cobra.Command{
+	Use:   "migrate-key",
+	Short: "Move the API key between config.json and the OS secret store",
+	Long: `Move the API key between config.json and the OS secret store.
+
+--to secret-service stores the key in the freedesktop Secret Service (GNOME
+Keyring, KWallet) via secret-tool and removes it from config.json.
+--to file moves it back.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		global := loadGlobal()
+		if global.APIKeyCommand != "" {
+			fmt.Println("Error: the key is read from api_key_command; unset it first to use a store")
+			os.Exit(1)
+		}
+
+		switch migrateToFlag {
+		case config.CredentialStoreSecretService:
+			if global.CredentialStore == config.CredentialStoreSecretService {
+				fmt.Println("The API key is already kept in the Secret Service.")
+				return
+			}
+			if global.APIKey == "" {
+				fmt.Println("Error: no API key in config.json to migrate")
+				os.Exit(1)
+			}
+			// Save moves the key into the store and blanks it in the file
+			global.CredentialStore = config.CredentialStoreSecretService
+			saveGlobal(global)
+			fmt.Println("API key moved to the Secret Service and removed from config.json.")
+
+		case config.CredentialStoreFile:
+			if global.CredentialStore != config.CredentialStoreSecretService {
+				fmt.Println("The API key is already kept in config.json.")
+				return
+			}
+			if err := global.ResolveAPIKey(); err != nil {
+				fmt.Printf("Error reading API key: %v\n", err)
+				os.Exit(1)
+			}
+			store := credentials.SecretService()
+			global.CredentialStore = ""
+			saveGlobal(global)
+			if err := store.Delete(); err != nil {
+				fmt.Printf("Warning: key saved to config.json but not removed from the Secret Service: %v\n", err)
+				return
+			}
+			fmt.Println("API key moved to config.json.")
+
+		default:
+			fmt.Printf("Error: --to must be %q or %q\n", config.CredentialStoreSecretService, config.CredentialStoreFile)
+			os.Exit(1)
+		}
+	},
+}
+
+// requireConfig exits if the effective config failed to load.
+func requireConfig() {
+	if cfgErr != nil {
+		fmt.Printf("Error loading config: %v\n", cfgErr)
+		os.Exit(1)
+	}
+}
+
+func loadGlobal() *config.Config {
+	global, err := config.LoadGlobal()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n(fix it with 'tinitui config edit')\n", err)
+		os.Exit(1)
+	}
+	return global
+}
+
+// saveGlobal validates and writes a changed global config.
+func saveGlobal(global *config.Config) {
+	if err := global.Validate(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := global.Save(); err != nil {
+		fmt.Printf("Error saving config: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// editorCommand returns the user's editor split into program and arguments.
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
-	configCmd.AddCommand(setKeyCmd)
+	configCmd.AddCommand(setKeyCmd, configGetCmd, configSetCmd, configUnsetCmd,
+		configListCmd, configPathCmd, configEditCmd, configValidateCmd, configMigrateKeyCmd)
+	configMigrateKeyCmd.Flags().StringVar(&migrateToFlag, "to", "", "Where to keep the key: secret-service or file")
+	configListCmd.Flags().BoolVar(&showOriginFlag, "show-origin", false, "Show where each value comes from")
+	setKeyCmd.Flags().BoolVar(&noVerifyFlag, "no-verify", false, "Save the key without checking it against the API")
 }
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gmsakibursabbir/tinitui/internal/tinify"
+)
+
+// Exit codes, stable for scripts and CI.
+const (
+	exitOK          = 0 // Every image compressed or skipped
+	exitFailures    = 1 // Some images failed
+	exitConfig      = 2 // Bad flags, config or API key
+	exitQuota       = 3 // The API's compression quota is used up
+	exitNothingToDo = 4 // No images to compress
+)
+
+// fatalf prints an error to stderr and exits with code.
+func fatalf(code int, format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(code)
+}
+
+// abortCode returns the exit code of a job error that makes every further
+// upload fail too, or exitOK.
+func abortCode(err error) int {
+	var apiErr *tinify.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized:
+			return exitConfig
+		case http.StatusTooManyRequests:
+			return exitQuota
+		}
+	}
+	return exitOK
+}
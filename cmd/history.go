@@ -1,43 +1,346 @@
 package cmd
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/gmsakibursabbir/tinitui/internal/history"
 	"github.com/spf13/cobra"
 )
 
-var csvOutput string
+var (
+	csvOutput       string
+	jsonOutputFlag  bool
+	markdownFlag    bool
+	sinceFlag       string
+	untilFlag       string
+	statusFlag      string
+	pathPrefixFlag  string
+	sessionFlag     string
+	historySortFlag string
+	reverseFlag     bool
+	limitFlag       int
+	groupByFlag     string
+	archivedFlag    bool
+	tsvOutput       string
+	ndjsonOutput    string
+	columnsFlag     []string
+	olderThanFlag   string
+)
 
 var historyCmd = &cobra.Command{
 	Use:   "history",
 	Short: "View compression history",
+	Long: `View compression history.
+
+Records can be filtered by time, status, path and session, sorted, limited
+and grouped by day, month, directory or format with totals saved.`,
+	Example: `  tinitui history --since 7d --status failed
+  tinitui history --group-by month --markdown
+  tinitui history --session 20240131T020000-1a2b3c4d --json`,
 	Run: func(cmd *cobra.Command, args []string) {
+		formats := 0
+		for _, set := range []bool{csvOutput != "", tsvOutput != "", ndjsonOutput != "", jsonOutputFlag, markdownFlag} {
+			if set {
+				formats++
+			}
+		}
+		if formats > 1 {
+			fmt.Println("Error: use only one of --csv, --tsv, --ndjson, --json and --markdown")
+			os.Exit(1)
+		}
+		if err := history.CheckColumns(columnsFlag); err != nil {
+			fmt.Printf("Error: --columns: %v\n", err)
+			os.Exit(1)
+		}
+
+		q, err := historyQuery()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
 		hMgr, err := history.New()
 		if err != nil {
 			fmt.Printf("Error loading history: %v\n", err)
-			return
+			os.Exit(1)
+		}
+		records, err := hMgr.Query(q)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
 
-		if csvOutput != "" {
-			if err := hMgr.ExportCSV(csvOutput); err != nil {
-				fmt.Printf("Error exporting CSV: %v\n", err)
+		var groups []history.Group
+		if groupByFlag != "" {
+			if groups, err = history.Aggregate(records, groupByFlag); err != nil {
+				fmt.Printf("Error: %v\n", err)
 				os.Exit(1)
 			}
-			fmt.Printf("Exported history to %s\n", csvOutput)
-			return
 		}
 
-		// Dump table
-		records := hMgr.All()
-		for _, r := range records {
-			fmt.Printf("%s\t%s\tSaved: %d bytes\n", r.Timestamp.Format("2006-01-02 15:04"), r.File, r.SavedBytes)
+		switch {
+		case csvOutput != "":
+			err = exportTo(csvOutput, func(w io.Writer) error {
+				if groups != nil {
+					return writeGroupsDelimited(w, groups, ',')
+				}
+				return history.WriteCSV(w, records, columnsFlag)
+			})
+		case tsvOutput != "":
+			err = exportTo(tsvOutput, func(w io.Writer) error {
+				if groups != nil {
+					return writeGroupsDelimited(w, groups, '\t')
+				}
+				return history.WriteTSV(w, records, columnsFlag)
+			})
+		case ndjsonOutput != "":
+			err = exportTo(ndjsonOutput, func(w io.Writer) error {
+				if groups != nil {
+					enc := json.NewEncoder(w)
+					for _, g := range groups {
+						if err := enc.Encode(g); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+				return history.WriteNDJSON(w, records, columnsFlag)
+			})
+		case jsonOutputFlag:
+			if groups != nil {
+				err = history.WriteJSON(os.Stdout, groups)
+			} else {
+				err = history.WriteJSON(os.Stdout, records)
+			}
+		case groups != nil:
+			err = writeGroups(os.Stdout, groups, markdownFlag)
+		default:
+			err = writeRecords(os.Stdout, records, markdownFlag)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// historyQuery builds the record filter from the command line flags.
+func historyQuery() (history.Query, error) {
+	q := history.Query{
+		Status:     statusFlag,
+		PathPrefix: pathPrefixFlag,
+		Session:    sessionFlag,
+		Sort:       historySortFlag,
+		Reverse:    reverseFlag,
+		Limit:      limitFlag,
+		Archived:   archivedFlag,
+	}
+	var err error
+	if sinceFlag != "" {
+		if q.Since, err = parseTime(sinceFlag); err != nil {
+			return q, fmt.Errorf("--since: %w", err)
+		}
+	}
+	if untilFlag != "" {
+		if q.Until, err = parseTime(untilFlag); err != nil {
+			return q, fmt.Errorf("--until: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// table writes rows aligned for the terminal or as a Markdown table.
+func table(w io.Writer, markdown bool, header []string, rows [][]string) error {
+	if markdown {
+		line := func(cells []string) {
+			escaped := make([]string, len(cells))
+			for i, c := range cells {
+				escaped[i] = strings.ReplaceAll(strings.ReplaceAll(c, "|", `\|`), "\n", " ")
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+		}
+		line(header)
+		sep := make([]string, len(header))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		line(sep)
+		for _, row := range rows {
+			line(row)
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeRecords(w io.Writer, records []*history.Record, markdown bool) error {
+	rows := make([][]string, 0, len(records))
+	for _, r := range records {
+		rows = append(rows, []string{
+			r.Timestamp.Format("2006-01-02 15:04"),
+			r.Status,
+			r.File,
+			formatBytes(r.BeforeSize),
+			formatBytes(r.AfterSize),
+			formatBytes(r.SavedBytes),
+			r.Error,
+		})
+	}
+	return table(w, markdown, []string{"Time", "Status", "File", "Before", "After", "Saved", "Error"}, rows)
+}
+
+func writeGroups(w io.Writer, groups []history.Group, markdown bool) error {
+	rows := make([][]string, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, []string{
+			g.Key,
+			strconv.Itoa(g.Records),
+			strconv.Itoa(g.Success),
+			strconv.Itoa(g.Failed),
+			strconv.Itoa(g.Skipped),
+			formatBytes(g.BeforeSize),
+			formatBytes(g.AfterSize),
+			fmt.Sprintf("%s (%.1f%%)", formatBytes(g.SavedBytes), g.SavedPercent),
+		})
+	}
+	return table(w, markdown, []string{groupByFlag, "Records", "OK", "Failed", "Skipped", "Before", "After", "Saved"}, rows)
+}
+
+// writeGroupsDelimited writes groups as CSV, or TSV with a tab comma.
+func writeGroupsDelimited(w io.Writer, groups []history.Group, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.Write([]string{"key", "records", "success", "failed", "skipped", "before_size", "after_size", "saved_bytes", "saved_percent"})
+	for _, g := range groups {
+		cw.Write([]string{
+			g.Key,
+			strconv.Itoa(g.Records),
+			strconv.Itoa(g.Success),
+			strconv.Itoa(g.Failed),
+			strconv.Itoa(g.Skipped),
+			strconv.FormatInt(g.BeforeSize, 10),
+			strconv.FormatInt(g.AfterSize, 10),
+			strconv.FormatInt(g.SavedBytes, 10),
+			strconv.FormatFloat(g.SavedPercent, 'f', 2, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportTo runs write on the file at path, or on stdout for "-". Exports to
+// a file are confirmed on stderr so stdout stays clean for pipes.
+func exportTo(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported history to %s\n", path)
+	return nil
+}
+
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Move old records to the compressed monthly archives",
+	Long: `Move old records to the compressed monthly archives.
+
+Archived records are kept in the archive directory next to the history log
+and are still searched by history and report with --archived. Lifetime
+totals (see history stats) include them.`,
+	Example: `  tinitui history prune --older-than 90d`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if olderThanFlag == "" {
+			fmt.Println("Error: --older-than is required")
+			os.Exit(1)
+		}
+		age, err := parseAge(olderThanFlag)
+		if err != nil {
+			fmt.Printf("Error: --older-than: %v\n", err)
+			os.Exit(1)
+		}
+		hMgr, err := history.New()
+		if err != nil {
+			fmt.Printf("Error loading history: %v\n", err)
+			os.Exit(1)
+		}
+		n, err := hMgr.Prune(time.Now().Add(-age))
+		if err != nil {
+			fmt.Printf("Error pruning history: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Archived %d records, %d remain in the history log.\n", n, len(hMgr.All()))
+	},
+}
+
+var historyStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show lifetime totals, including archived records",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hMgr, err := history.New()
+		if err != nil {
+			fmt.Printf("Error loading history: %v\n", err)
+			os.Exit(1)
+		}
+		g, err := hMgr.Lifetime()
+		if err != nil {
+			fmt.Printf("Error reading stats: %v\n", err)
+			os.Exit(1)
 		}
+		fmt.Printf("Records         : %d\n", g.Records)
+		fmt.Printf("Compressed      : %d\n", g.Success)
+		fmt.Printf("Failed          : %d\n", g.Failed)
+		fmt.Printf("Skipped         : %d\n", g.Skipped)
+		fmt.Printf("Total before    : %s\n", formatBytes(g.BeforeSize))
+		fmt.Printf("Total after     : %s\n", formatBytes(g.AfterSize))
+		fmt.Printf("Total saved     : %s (%.0f%%)\n", formatBytes(g.SavedBytes), g.SavedPercent)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(historyCmd)
-	historyCmd.Flags().StringVar(&csvOutput, "csv", "", "Export history to CSV file")
+	historyCmd.Long += "\n\nExport columns: " + strings.Join(history.Columns, ", ") + "."
+	historyCmd.AddCommand(historyPruneCmd, historyStatsCmd)
+	historyPruneCmd.Flags().StringVar(&olderThanFlag, "older-than", "", "Archive records older than this age, e.g. 90d")
+	f := historyCmd.Flags()
+	f.StringVar(&csvOutput, "csv", "", "Export history to a CSV file, or - for stdout")
+	f.StringVar(&tsvOutput, "tsv", "", "Export history to a tab-separated file, or - for stdout")
+	f.StringVar(&ndjsonOutput, "ndjson", "", "Export one JSON object per line to a file, or - for stdout")
+	f.StringSliceVar(&columnsFlag, "columns", nil, "Fields for --csv, --tsv and --ndjson, e.g. timestamp,file,saved_bytes")
+	f.BoolVar(&jsonOutputFlag, "json", false, "Print records or groups as JSON")
+	f.BoolVar(&markdownFlag, "markdown", false, "Print a Markdown table")
+	f.StringVar(&sinceFlag, "since", "", "Only records at or after a date (2024-01-31) or age (7d)")
+	f.StringVar(&untilFlag, "until", "", "Only records before a date or age")
+	f.StringVar(&statusFlag, "status", "", "Only records with this status: success, failed or skipped")
+	f.StringVar(&pathPrefixFlag, "path-prefix", "", "Only files under this path")
+	f.StringVar(&sessionFlag, "session", "", "Only records of one compress run")
+	f.StringVar(&historySortFlag, "sort", "time", "Order by time (newest first), file, saved or size")
+	f.BoolVar(&reverseFlag, "reverse", false, "Reverse the order, e.g. oldest first")
+	f.IntVar(&limitFlag, "limit", 0, "Show at most this many records")
+	f.StringVar(&groupByFlag, "group-by", "", "Total records per day, month, dir or format")
+	f.BoolVar(&archivedFlag, "archived", false, "Also search archived records")
 }
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/pipeline"
+)
+
+// Values of compress --output.
+const (
+	outputTable  = "table"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+	outputQuiet  = "quiet"
+)
+
+// outputSchemaVersion is bumped when a field of the JSON output changes
+// meaning or is removed; new fields may appear without a bump.
+const outputSchemaVersion = 1
+
+// jobOutput is a job in the JSON and NDJSON output.
+type jobOutput struct {
+	Input          string  `json:"input"`
+	Output         string  `json:"output"`
+	Status         string  `json:"status"` // pending, processing, done, failed, skipped or cancelled
+	Format         string  `json:"format,omitempty"`
+	Width          int     `json:"width,omitempty"`
+	Height         int     `json:"height,omitempty"`
+	OriginalSize   int64   `json:"original_size"`
+	CompressedSize int64   `json:"compressed_size"`
+	SavedBytes     int64   `json:"saved_bytes"`
+	SavedPercent   float64 `json:"saved_percent"`
+	DurationMS     int64   `json:"duration_ms"`
+	Retries        int     `json:"retries"`
+	Error          string  `json:"error,omitempty"`
+	SkipReason     string  `json:"skip_reason,omitempty"`
+	HookError      string  `json:"hook_error,omitempty"` // The after_compress hook failed
+	Warning        string  `json:"warning,omitempty"`    // The content disagrees with the extension
+}
+
+func newJobOutput(job *pipeline.Job) jobOutput {
+	out := jobOutput{
+		Input:          job.FilePath,
+		Output:         job.OutputPath,
+		Status:         string(job.Status),
+		Format:         job.Format,
+		Width:          job.Width,
+		Height:         job.Height,
+		OriginalSize:   job.OriginalSize,
+		CompressedSize: job.CompressedSize,
+		SavedBytes:     job.SavedBytes,
+		SavedPercent:   job.SavedPercent,
+		DurationMS:     job.Duration.Milliseconds(),
+		Retries:        job.Retries,
+		SkipReason:     job.SkipReason,
+		Warning:        job.Warning,
+	}
+	if job.Error != nil {
+		out.Error = job.Error.Error()
+	}
+	if job.HookError != nil {
+		out.HookError = job.HookError.Error()
+	}
+	return out
+}
+
+// runSummary totals a compress run.
+type runSummary struct {
+	Total          int     `json:"total"`
+	Done           int     `json:"done"`
+	Failed         int     `json:"failed"`
+	Skipped        int     `json:"skipped"`
+	Cancelled      int     `json:"cancelled"` // Left when the run was aborted
+	OriginalSize   int64   `json:"original_size"`
+	CompressedSize int64   `json:"compressed_size"`
+	SavedBytes     int64   `json:"saved_bytes"`
+	SavedPercent   float64 `json:"saved_percent"`
+	DurationMS     int64   `json:"duration_ms"`
+	ExitCode       int     `json:"exit_code"`
+}
+
+// add counts a job that finished or was cancelled, and ignores other
+// status changes.
+func (s *runSummary) add(job *pipeline.Job) {
+	if job.Status == pipeline.StatusCancelled {
+		s.Cancelled++
+		return
+	}
+	if !job.Status.IsTerminal() {
+		return
+	}
+	s.Total++
+	switch job.Status {
+	case pipeline.StatusDone:
+		s.Done++
+		s.OriginalSize += job.OriginalSize
+		s.CompressedSize += job.CompressedSize
+		s.SavedBytes += job.SavedBytes
+		if s.OriginalSize > 0 {
+			s.SavedPercent = float64(s.SavedBytes) / float64(s.OriginalSize) * 100
+		}
+	case pipeline.StatusFailed:
+		s.Failed++
+	case pipeline.StatusSkipped:
+		s.Skipped++
+	}
+}
+
+// runOutput presents a compress run. event is called for every job status
+// change, finish once all jobs are done.
+type runOutput interface {
+	event(e pipeline.Event)
+	finish(sum runSummary)
+}
+
+// checkOutput returns an error unless format is a value of --output.
+func checkOutput(format string) error {
+	switch format {
+	case "", outputTable, outputJSON, outputNDJSON, outputQuiet:
+		return nil
+	}
+	return fmt.Errorf("--output must be %s, %s, %s or %s", outputTable, outputJSON, outputNDJSON, outputQuiet)
+}
+
+func newRunOutput(format string, w io.Writer, session string) (runOutput, error) {
+	if err := checkOutput(format); err != nil {
+		return nil, err
+	}
+	switch format {
+	case "", outputTable:
+		return &tableRun{w: tabwriter.NewWriter(w, 0, 0, 3, ' ', 0), out: w}, nil
+	case outputJSON:
+		return &jsonRun{enc: json.NewEncoder(w), session: session, jobs: []jobOutput{}}, nil
+	case outputNDJSON:
+		return &ndjsonRun{enc: json.NewEncoder(w), session: session}, nil
+	}
+	return quietRun{}, nil
+}
+
+// tableRun prints a row per finished job and a summary for people.
+type tableRun struct {
+	w             *tabwriter.Writer
+	out           io.Writer
+	headerPrinted bool
+}
+
+func (t *tableRun) event(e pipeline.Event) {
+	job := &e.Job
+	if !job.Status.IsTerminal() {
+		return
+	}
+	if !t.headerPrinted {
+		fmt.Fprintln(t.w, "Status\tFile\tBefore\tAfter\tSaved %\tError")
+		t.headerPrinted = true
+	}
+	errStr := job.SkipReason
+	if job.Error != nil {
+		errStr = job.Error.Error()
+	} else if job.HookError != nil {
+		errStr = job.HookError.Error()
+	} else if errStr == "" {
+		errStr = job.Warning
+	}
+	fmt.Fprintf(t.w, "%s\t%s\t%s\t%s\t%.1f%%\t%s\n",
+		string(job.Status),
+		shortPath(job.FilePath),
+		formatBytes(job.OriginalSize),
+		formatBytes(job.CompressedSize),
+		job.SavedPercent,
+		errStr,
+	)
+	t.w.Flush()
+}
+
+func (t *tableRun) finish(sum runSummary) {
+	if sum.Total == 0 && sum.Cancelled == 0 {
+		fmt.Fprintln(t.out, "No images found.")
+		return
+	}
+	fmt.Fprintln(t.out, "--------------------------------------------------")
+	fmt.Fprintf(t.out, "Compression complete ✔\n")
+	fmt.Fprintf(t.out, "Files processed : %d\n", sum.Done)
+	fmt.Fprintf(t.out, "Total before    : %s\n", formatBytes(sum.OriginalSize))
+	fmt.Fprintf(t.out, "Total after     : %s\n", formatBytes(sum.CompressedSize))
+	fmt.Fprintf(t.out, "Total saved     : %s (%.0f%%)\n", formatBytes(sum.SavedBytes), sum.SavedPercent)
+	fmt.Fprintf(t.out, "Skipped         : %d\n", sum.Skipped)
+	fmt.Fprintf(t.out, "Errors          : %d\n", sum.Failed)
+	if sum.Cancelled > 0 {
+		fmt.Fprintf(t.out, "Cancelled       : %d\n", sum.Cancelled)
+	}
+}
+
+// jsonRun prints one document with every finished job and the summary.
+type jsonRun struct {
+	enc     *json.Encoder
+	session string
+	jobs    []jobOutput
+}
+
+func (j *jsonRun) event(e pipeline.Event) {
+	if e.Job.Status.IsTerminal() {
+		j.jobs = append(j.jobs, newJobOutput(&e.Job))
+	}
+}
+
+func (j *jsonRun) finish(sum runSummary) {
+	j.enc.SetIndent("", "  ")
+	j.enc.Encode(struct {
+		SchemaVersion int         `json:"schema_version"`
+		Session       string      `json:"session"`
+		Jobs          []jobOutput `json:"jobs"`
+		Summary       runSummary  `json:"summary"`
+	}{outputSchemaVersion, j.session, j.jobs, sum})
+}
+
+// ndjsonRun prints a "job" object per status change and a final
+// "summary" object, one per line.
+type ndjsonRun struct {
+	enc     *json.Encoder
+	session string
+}
+
+func (n *ndjsonRun) event(e pipeline.Event) {
+	n.enc.Encode(struct {
+		SchemaVersion int       `json:"schema_version"`
+		Type          string    `json:"type"`
+		Time          time.Time `json:"time"`
+		Session       string    `json:"session"`
+		jobOutput
+	}{outputSchemaVersion, "job", e.Time, n.session, newJobOutput(&e.Job)})
+}
+
+func (n *ndjsonRun) finish(sum runSummary) {
+	n.enc.Encode(struct {
+		SchemaVersion int       `json:"schema_version"`
+		Type          string    `json:"type"`
+		Time          time.Time `json:"time"`
+		Session       string    `json:"session"`
+		runSummary
+	}{outputSchemaVersion, "summary", time.Now(), n.session, sum})
+}
+
+// quietRun prints nothing; the exit code and stderr tell what happened.
+type quietRun struct{}
+
+func (quietRun) event(pipeline.Event) {}
+
+func (quietRun) finish(runSummary) {}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseSize parses sizes like "500", "200KB", "1.5MB" or "2G" into bytes.
+// Units are binary (1 KB = 1024 bytes), matching formatBytes.
+func parseSize(orig string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(orig))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", orig)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// parseDimensions parses "WxH" pixel dimensions such as "800x600"; 0
+// leaves a side unbounded.
+func parseDimensions(orig string) (w, h int, err error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(strings.TrimSpace(orig)), "x")
+	if ok {
+		w, err = strconv.Atoi(ws)
+	}
+	if ok && err == nil {
+		h, err = strconv.Atoi(hs)
+	}
+	if !ok || err != nil || w < 0 || h < 0 {
+		return 0, 0, fmt.Errorf("invalid dimensions %q: use WxH, e.g. 800x600", orig)
+	}
+	return w, h, nil
+}
+
+// parseAge parses a duration that also accepts days and weeks ("90d", "2w"),
+// on top of what time.ParseDuration understands.
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// parseTime parses an absolute time (RFC 3339, "2006-01-02 15:04" or a
+// date in local time) or an age relative to now ("7d", "36h").
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	d, err := parseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use a date like 2024-01-31 or an age like 7d", s)
+	}
+	return time.Now().Add(-d), nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+	"github.com/gmsakibursabbir/tinitui/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var (
+	htmlOutput  string
+	reportTitle string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Render a savings report from history",
+	Long: `Render a savings report from history.
+
+The HTML report is a single file with totals, a chart of savings over time,
+breakdowns per directory and format, the largest wins and the failures. It
+takes the same --since, --until, --path-prefix, --session, --status and
+--archived filters as history.`,
+	Example: `  tinitui report --html savings.html --since 30d`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if htmlOutput == "" {
+			fmt.Println("Error: --html <file> is required")
+			os.Exit(1)
+		}
+		q, err := historyQuery()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		hMgr, err := history.New()
+		if err != nil {
+			fmt.Printf("Error loading history: %v\n", err)
+			os.Exit(1)
+		}
+		records, err := hMgr.Query(q)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		r, err := report.Build(reportTitle, records)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		f, err := os.Create(htmlOutput)
+		if err == nil {
+			err = report.WriteHTML(f, r)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error writing report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Report of %d records written to %s\n", len(records), htmlOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	f := reportCmd.Flags()
+	f.StringVar(&htmlOutput, "html", "", "Write an HTML report to this file")
+	f.StringVar(&reportTitle, "title", "Image compression report", "Heading of the report")
+	f.StringVar(&sinceFlag, "since", "", "Only records at or after a date (2024-01-31) or age (7d)")
+	f.StringVar(&untilFlag, "until", "", "Only records before a date or age")
+	f.StringVar(&statusFlag, "status", "", "Only records with this status: success, failed or skipped")
+	f.StringVar(&pathPrefixFlag, "path-prefix", "", "Only files under this path")
+	f.StringVar(&sessionFlag, "session", "", "Only records of one compress run")
+	f.BoolVar(&archivedFlag, "archived", false, "Also include archived records")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -12,6 +13,7 @@ import (
 
 var (
 	cfg         *config.Config
+	cfgErr      error // Why the config failed to load, for config subcommands
 	showVersion bool
 )
 
@@ -19,12 +21,20 @@ var rootCmd = &cobra.Command{
 	Use:   "tinitui",
 	Short: "TiniTUI is a TUI for compressing images via TinyPNG",
 	Long:  `A modern, beautiful Terminal User Interface for compressing images using the TinyPNG API.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		initConfig(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if showVersion {
 			fmt.Printf("tinitui version %s\n", version.Version)
 			return
 		}
 
+		if err := cfg.ResolveAPIKey(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading API key: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Default action: Run TUI
 		// Pass cfg to TUI
 		tui.Start(cfg)
@@ -33,24 +43,36 @@ var rootCmd = &cobra.Command{
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		// Unknown commands and flags
+		fatalf(exitConfig, "%v", err)
 	}
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
 	rootCmd.Flags().BoolVarP(&showVersion, "version", "v", false, "Show version information")
 }
 
-func initConfig() {
-	var err error
-	cfg, err = config.Load()
-	if err != nil {
-		// Log error but don't exit, might be first run
-		// However, TUI Setup needs to handle "New Config"
-		// If load fails because file doesn't exist, Load returns default config
-		// If it fails for other reasons (perm denied?), we might be in trouble.
-		// For now assume cfg is workable.
+func initConfig(cmd *cobra.Command) {
+	cfg, cfgErr = config.Load()
+	if cfgErr == nil {
+		for _, w := range cfg.Warnings() {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		return
+	}
+	// The config commands must still work to repair a broken file
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == configCmd {
+			cfg = config.DefaultConfig()
+			return
+		}
+	}
+	// A missing config file is not an error, Load returns defaults.
+	// Anything else (bad JSON/YAML, permissions) must be fixed first.
+	hint := "fix it with 'tinitui config edit'"
+	var envErr *config.EnvError
+	if errors.As(cfgErr, &envErr) {
+		hint = "fix or unset " + envErr.Name
 	}
+	fatalf(exitConfig, "Error loading config: %v\n(%s)", cfgErr, hint)
 }
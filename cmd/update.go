@@ -47,7 +47,7 @@ var updateCmd = &cobra.Command{
 		if err := updater.Update(release); err != nil {
 			fmt.Printf("\n❌ Update failed: %v\n", err)
 			fmt.Println("\nFix options:")
-			fmt.Println("1) Run: sudo tinytui update")
+			fmt.Println("1) Run: sudo tinitui update")
 			fmt.Println("2) Or reinstall using:")
 			fmt.Println("   curl -fsSL https://tinytui.dev/install.sh | sh")
 			os.Exit(1)
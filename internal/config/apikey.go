@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+
+	"github.com/gmsakibursabbir/tinitui/internal/credentials"
+)
+
+// credentialStore returns where the API key is kept outside config.json, or
+// nil when it lives in the file itself.
+func (c *Config) credentialStore() credentials.Store {
+	switch {
+	case c.APIKeyCommand != "":
+		return credentials.Command(c.APIKeyCommand)
+	case c.CredentialStore == CredentialStoreSecretService:
+		return credentials.SecretService()
+	}
+	return nil
+}
+
+// ResolveAPIKey reads the API key from the configured credential store. It
+// is separate from Load because helpers may prompt for a passphrase, which
+// only commands that talk to the API should trigger. A key from the
+// environment always wins. A store without a key leaves APIKey empty.
+func (c *Config) ResolveAPIKey() error {
+	if c.Origin("api_key") == SourceEnv {
+		return nil
+	}
+	store := c.credentialStore()
+	if store == nil {
+		return nil
+	}
+	key, err := store.Get()
+	if errors.Is(err, credentials.ErrNotFound) {
+		key, err = "", nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.APIKey = key
+	c.storedKey = key
+	c.setOrigin("api_key", Source(store.Name()))
+	// Like other overrides, a key from the store isn't written to the file
+	if c.loaded != nil {
+		c.loaded.APIKey = key
+	}
+	return nil
+}
+
+// storeAPIKey moves a changed API key from out, the settings about to be
+// written, into the credential store. The file keeps no key then.
+func (c *Config) storeAPIKey(out *Config) error {
+	// api_key_command is read-only; it simply wins over any key in the file
+	store := c.credentialStore()
+	if store == nil || c.APIKeyCommand != "" {
+		return nil
+	}
+	if out.APIKey != "" && out.APIKey != c.storedKey {
+		if err := store.Set(out.APIKey); err != nil {
+			return err
+		}
+		c.storedKey = out.APIKey
+	}
+	out.APIKey = ""
+	return nil
+}
@@ -1,9 +1,16 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/gmsakibursabbir/tinitui/internal/atomicfile"
+	"github.com/gmsakibursabbir/tinitui/internal/lock"
 )
 
 const (
@@ -22,8 +29,30 @@ const (
 	MascotAuto MascotMode = "auto"
 )
 
+// What a run does with a file another tinitui process is compressing.
+const (
+	OnLockedSkip = "skip"
+	OnLockedWait = "wait"
+)
+
+// Where the API key is kept.
+const (
+	CredentialStoreFile          = "file"
+	CredentialStoreSecretService = "secret-service"
+)
+
+// What a run does with animated PNG/WebP images.
+const (
+	AnimatedCompress = "compress"
+	AnimatedSkip     = "skip"
+	AnimatedError    = "error"
+)
+
 type Config struct {
+	Version      int        `json:"version"` // Schema version, see CurrentVersion
 	APIKey       string     `json:"api_key"`
+	APIKeyCommand string    `json:"api_key_command,omitempty"` // Prints the key, e.g. "pass show tinify"
+	CredentialStore string  `json:"credential_store,omitempty"` // "file" (default) or "secret-service"
 	OutputMode   string     `json:"output_mode"` // "replace" or "directory"
 	OutputDir    string     `json:"output_dir,omitempty"`
 	Suffix       string     `json:"suffix"`
@@ -31,71 +60,238 @@ type Config struct {
 	Mascot       MascotMode `json:"mascot"`
 	MascotType   string     `json:"mascot_type"` // "panda", "waifu1", "waifu2"
 	Concurrency  int        `json:"concurrency"`
+	OnLocked     string     `json:"on_locked"` // "skip" or "wait"
+	Animated     string     `json:"animated"`  // "compress", "skip" or "error"
+	MinBytes     int64      `json:"min_bytes,omitempty"`      // Skip smaller files, 0 = no limit
+	MaxBytes     int64      `json:"max_bytes,omitempty"`      // Skip larger files, 0 = no limit
+	MaxMegapixels float64   `json:"max_megapixels,omitempty"` // Skip larger images, 0 = no limit
+	FixExtensions bool      `json:"fix_extensions"` // Name outputs after the detected format
+	Ignore       []string   `json:"ignore,omitempty"` // gitignore-syntax patterns left out of scans
+	Hooks        Hooks      `json:"hooks"`
+	History      History    `json:"history"`
+	Profile      string     `json:"profile,omitempty"` // Active entry of Profiles
+	Profiles     map[string]Profile `json:"profiles,omitempty"`
 	configPath   string
+	projectPath  string            // Project file in effect, if any
+	origins      map[string]Source // Where each setting came from, by JSON name
+	global       *Config           // Values from defaults and the global file only
+	base         *Config           // Values before the profile was applied
+	baseOrigins  map[string]Source
+	loaded       *Config           // Values right after Load, with all overrides
+	storedKey    string            // Key last read from or written to the credential store
+	warnings     []string          // Problems found by Load that didn't stop it
+}
+
+// Source is where the value of a setting came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceGlobal  Source = "global"
+	SourceProject Source = "project"
+	SourceProfile Source = "profile"
+	SourceEnv     Source = "env"
+	// The API key can also come from a credential store
+	SourceSecretService Source = "secret-service"
+	SourceCommand       Source = "api_key_command"
+)
+
+// Hooks are shell commands run around compression. Per-job hooks receive the
+// job as JSON on stdin and as TINITUI_JOB_* environment variables; on_complete
+// receives the run summary the same way with TINITUI_RUN_* variables.
+type Hooks struct {
+	BeforeCompress string `json:"before_compress,omitempty"`
+	AfterCompress  string `json:"after_compress,omitempty"`
+	OnFailure      string `json:"on_failure,omitempty"`
+	OnComplete     string `json:"on_complete,omitempty"`
+	Timeout        int    `json:"timeout_seconds,omitempty"` // Per hook, 30s when unset
+}
+
+// History limits the live history log. Records beyond any limit are moved
+// to compressed monthly archives when history is saved; 0 = no limit.
+type History struct {
+	MaxAgeDays int   `json:"max_age_days,omitempty"`
+	MaxRecords int   `json:"max_records,omitempty"`
+	MaxBytes   int64 `json:"max_bytes,omitempty"` // Size of the log file
 }
 
 func DefaultConfig() *Config {
 	return &Config{
+		Version:     CurrentVersion,
 		OutputMode:  "replace",
 		Suffix:      ".tiny",
 		Metadata:    false,
 		Mascot:      MascotAuto,
 		MascotType:  "panda",
 		Concurrency: 2,
+		OnLocked:    OnLockedSkip,
+		Animated:    AnimatedCompress,
 	}
 }
 
-// Load reads the configuration from the standard config location.
-// It also checks the TINYPNG_API_KEY environment variable.
+// Load reads the configuration from the standard config location, then
+// applies the project file found from the working directory, the active
+// profile and finally environment variables. Later sources win:
+// env > profile > project > global > defaults. Older global files are
+// migrated to CurrentVersion first. Problems Validate finds in the result
+// are returned by Warnings.
 func Load() (*Config, error) {
 	cfg := DefaultConfig()
 
-	configDir, err := os.UserConfigDir()
+	path, err := GlobalPath()
 	if err != nil {
 		return nil, err
 	}
-
-	path := filepath.Join(configDir, DirName, ConfigName)
 	cfg.configPath = path
 
-	// Check if file exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		// If not exists, check env var first
-		if envKey := os.Getenv(EnvAPIKey); envKey != "" {
-			cfg.APIKey = envKey
+	// A missing file just means defaults
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := cfg.applyGlobal(path, data); err != nil {
+			return nil, err
 		}
-		// Return default with potentially env key set.
-		// We don't save yet.
-		return cfg, nil
 	}
-
-	// Read file
-	data, err := os.ReadFile(path)
-	if err != nil {
+	if cfg.global, err = cfg.snapshot(); err != nil {
 		return nil, err
 	}
 
-	if err := json.Unmarshal(data, cfg); err != nil {
+	if cwd, err := os.Getwd(); err == nil {
+		if project := FindProjectFile(cwd); project != "" {
+			if err := cfg.loadProject(project); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// The profile can be picked from the environment too
+	if err := cfg.applyEnv("profile"); err != nil {
+		return nil, err
+	}
+	if err := cfg.SelectProfile(cfg.Profile); err != nil {
 		return nil, err
 	}
 
-	// Environment variable overrides config file
-	if envKey := os.Getenv(EnvAPIKey); envKey != "" {
-		cfg.APIKey = envKey
+	// Environment variables override config files
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
 	}
 
+	// Invalid settings are reported but don't stop loading, or a value
+	// older releases accepted would lock users out of every command,
+	// including the ones that fix it
+	if err := cfg.Validate(); err != nil {
+		cfg.warnings = append(cfg.warnings, strings.Split(err.Error(), "\n")...)
+	}
+	if cfg.loaded, err = cfg.snapshot(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
+// applyGlobal migrates and applies the contents of the global config file.
+func (c *Config) applyGlobal(path string, data []byte) error {
+	data, err := migrate(path, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.apply(data, SourceGlobal); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	c.checkUnknown(path, data)
+	return nil
+}
+
+// apply merges JSON settings into c, recording src as their origin.
+func (c *Config) apply(data []byte, src Source) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return err
+	}
+	for k := range keys {
+		c.setOrigin(k, src)
+	}
+	return nil
+}
+
+func (c *Config) setOrigin(key string, src Source) {
+	if c.origins == nil {
+		c.origins = make(map[string]Source)
+	}
+	c.origins[key] = src
+}
+
+// Origin reports where the setting with the given JSON name came from.
+// Nested settings inherit the origin of their parent object.
+func (c *Config) Origin(key string) Source {
+	for {
+		if src, ok := c.origins[key]; ok {
+			return src
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return SourceDefault
+		}
+		key = key[:i]
+	}
+}
+
+// ProjectPath returns the project file applied by Load, or "" if none.
+func (c *Config) ProjectPath() string {
+	return c.projectPath
+}
+
+// snapshot deep-copies the settings of c without its bookkeeping. It fails
+// for values JSON can't hold, which Set and Validate reject.
+func (c *Config) snapshot() (*Config, error) {
+	out := &Config{}
+	data, err := json.Marshal(c)
+	if err == nil {
+		err = json.Unmarshal(data, out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return out, nil
+}
+
+// persisted returns the settings Save writes to the global file. Values
+// overridden by a project file or the environment are written with their
+// global value, unless they were changed after loading.
+func (c *Config) persisted() (*Config, error) {
+	out, err := c.snapshot()
+	if err != nil || c.global == nil || c.loaded == nil {
+		return out, err
+	}
+	cur := reflect.ValueOf(out).Elem()
+	global := reflect.ValueOf(c.global).Elem()
+	loaded := reflect.ValueOf(c.loaded).Elem()
+	for i := 0; i < cur.NumField(); i++ {
+		if !cur.Type().Field(i).IsExported() {
+			continue
+		}
+		v := cur.Field(i).Interface()
+		if reflect.DeepEqual(v, loaded.Field(i).Interface()) && !reflect.DeepEqual(v, global.Field(i).Interface()) {
+			cur.Field(i).Set(global.Field(i))
+		}
+	}
+	return out, nil
+}
+
 // Save writes the configuration to the file with strict permissions.
 func (c *Config) Save() error {
 	if c.configPath == "" {
 		// Re-derive path if missing (shouldn't happen if loaded via Load)
-		configDir, err := os.UserConfigDir()
+		path, err := GlobalPath()
 		if err != nil {
 			return err
 		}
-		c.configPath = filepath.Join(configDir, DirName, ConfigName)
+		c.configPath = path
 	}
 
 	dir := filepath.Dir(c.configPath)
@@ -103,12 +299,66 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	out, err := c.persisted()
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(c.configPath, data, PermFile)
+	// Serialise with other tinitui processes writing the same file, and keep
+	// what they saved since this one loaded it
+	l, err := lock.Acquire(context.Background(), c.configPath+".lock")
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	if err := c.mergeSaved(out); err != nil {
+		return err
+	}
+	if err := c.storeAPIKey(out); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(c.configPath, data, PermFile); err != nil {
+		return err
+	}
+	c.global = out
+	return nil
+}
+
+// mergeSaved sets the settings of out that c hasn't changed since loading
+// to their value in the global file, which other processes may have saved
+// meanwhile. The file is expected to be migrated already, by Load.
+func (c *Config) mergeSaved(out *Config) error {
+	if c.global == nil {
+		return nil
+	}
+	data, err := os.ReadFile(c.configPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	saved := DefaultConfig()
+	if err := json.Unmarshal(data, saved); err != nil {
+		return fmt.Errorf("%s: %w", c.configPath, err)
+	}
+	cur := reflect.ValueOf(out).Elem()
+	global := reflect.ValueOf(c.global).Elem()
+	disk := reflect.ValueOf(saved).Elem()
+	for i := 0; i < cur.NumField(); i++ {
+		if !cur.Type().Field(i).IsExported() {
+			continue
+		}
+		if reflect.DeepEqual(cur.Field(i).Interface(), global.Field(i).Interface()) {
+			cur.Field(i).Set(disk.Field(i))
+		}
+	}
+	return nil
 }
 
 // ShouldShowMascot determines if mascot should be shown based on logic and terminal width
@@ -1,7 +1,13 @@
 package config
 
 import (
+	"encoding/json"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -65,3 +71,440 @@ func TestMascotLogic(t *testing.T) {
 		t.Error("Off should always be false")
 	}
 }
+
+func TestYAMLToJSON(t *testing.T) {
+	got, err := yamlToJSON([]byte(`
+# Project settings
+output_mode: directory
+output_dir: "dist/img"   # relative to this file
+suffix: ''
+concurrency: 3
+max_megapixels: 12.5
+fix_extensions: true
+ignore:
+  - "*.min.png"
+  - vendor/
+hooks:
+  timeout_seconds: 10
+tags: [a, 'b c']
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"concurrency":3,"fix_extensions":true,"hooks":{"timeout_seconds":10},"ignore":["*.min.png","vendor/"],` +
+		`"max_megapixels":12.5,"output_dir":"dist/img","output_mode":"directory","suffix":"","tags":["a","b c"]}`
+	if string(got) != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+
+	for _, bad := range []string{"a: 1\n  b: 2", "- a\n- b", "a: [1, 2", "a: 1\na: 2"} {
+		if _, err := yamlToJSON([]byte(bad)); err == nil {
+			t.Errorf("yamlToJSON(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestProjectOverrides(t *testing.T) {
+	t.Setenv(EnvAPIKey, "")
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	global := `{"output_mode": "replace", "suffix": ".min", "concurrency": 4}`
+	if err := os.MkdirAll(filepath.Join(configHome, DirName), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configHome, DirName, ConfigName), []byte(global), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, ".tinitui.yaml"), []byte("output_mode: directory\noutput_dir: out\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(project, "assets", "img")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(sub)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OutputMode != "directory" || cfg.OutputDir != filepath.Join(project, "out") {
+		t.Errorf("project not applied: mode %q dir %q", cfg.OutputMode, cfg.OutputDir)
+	}
+	if cfg.Suffix != ".min" || cfg.Concurrency != 4 {
+		t.Errorf("global not applied: suffix %q concurrency %d", cfg.Suffix, cfg.Concurrency)
+	}
+	if cfg.Origin("output_mode") != SourceProject || cfg.Origin("suffix") != SourceGlobal || cfg.Origin("mascot") != SourceDefault {
+		t.Errorf("wrong origins: %v", cfg.origins)
+	}
+
+	// Saving keeps project values out of the global file
+	cfg.Concurrency = 1
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(configHome, DirName, ConfigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Config
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.OutputMode != "replace" || saved.OutputDir != "" || saved.Concurrency != 1 {
+		t.Errorf("saved mode %q dir %q concurrency %d", saved.OutputMode, saved.OutputDir, saved.Concurrency)
+	}
+
+	if err := os.WriteFile(filepath.Join(project, ".tinitui.yaml"), []byte("api_key: secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("api_key in a project file was accepted")
+	}
+}
+
+func TestSelectProfile(t *testing.T) {
+	t.Setenv(EnvAPIKey, "")
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Chdir(t.TempDir())
+	global := `{
+  "suffix": ".tiny",
+  "profile": "web",
+  "profiles": {
+    "web": {"output_mode": "directory", "output_dir": "web", "concurrency": 4},
+    "store": {"suffix": ""}
+  }
+}`
+	path := filepath.Join(configHome, DirName, ConfigName)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(global), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OutputMode != "directory" || cfg.Concurrency != 4 || cfg.Origin("output_dir") != SourceProfile {
+		t.Errorf("web profile not applied: %+v", cfg)
+	}
+
+	// Switching undoes the previous profile
+	if err := cfg.SelectProfile("store"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OutputMode != "replace" || cfg.Concurrency != 2 || cfg.Suffix != "" {
+		t.Errorf("store profile: mode %q concurrency %d suffix %q", cfg.OutputMode, cfg.Concurrency, cfg.Suffix)
+	}
+	if cfg.Origin("output_mode") != SourceDefault || cfg.Origin("suffix") != SourceProfile {
+		t.Errorf("wrong origins: %v", cfg.origins)
+	}
+	if err := cfg.SelectProfile("nope"); err == nil {
+		t.Error("unknown profile accepted")
+	}
+
+	// Only the choice of profile is saved
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Config
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Profile != "store" || saved.Suffix != ".tiny" || len(saved.Profiles) != 2 {
+		t.Errorf("saved profile %q suffix %q profiles %d", saved.Profile, saved.Suffix, len(saved.Profiles))
+	}
+}
+
+func TestSetGetUnset(t *testing.T) {
+	cfg := DefaultConfig()
+	for key, value := range map[string]string{
+		"concurrency":           "3",
+		"metadata":              "true",
+		"max_megapixels":        "12.5",
+		"ignore":                "a.png, vendor/",
+		"hooks.timeout_seconds": "10",
+	} {
+		if err := cfg.Set(key, value); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	if cfg.Concurrency != 3 || !cfg.Metadata || cfg.MaxMegapixels != 12.5 || cfg.Hooks.Timeout != 10 {
+		t.Errorf("values not set: %+v", cfg)
+	}
+	if got, _ := cfg.Get("ignore"); got != "a.png,vendor/" {
+		t.Errorf("Get(ignore) = %q", got)
+	}
+
+	for _, kv := range [][2]string{
+		{"concurrency", "many"}, {"metadata", "maybe"}, {"nope", "1"}, {"profiles", "{}"},
+		{"max_megapixels", "NaN"}, {"max_megapixels", "+Inf"}, {"max_megapixels", "-1"},
+	} {
+		if err := cfg.Set(kv[0], kv[1]); err == nil {
+			t.Errorf("Set(%s, %s) succeeded", kv[0], kv[1])
+		}
+	}
+	if cfg.MaxMegapixels != 12.5 {
+		t.Errorf("rejected value stored: %v", cfg.MaxMegapixels)
+	}
+
+	if err := cfg.Unset("concurrency"); err != nil || cfg.Concurrency != 2 {
+		t.Errorf("Unset(concurrency): %v, got %d", err, cfg.Concurrency)
+	}
+
+	cfg.OutputMode = "somewhere"
+	cfg.MascotType = "cat"
+	if err := cfg.Validate(); err == nil {
+		t.Error("invalid enums passed validation")
+	}
+	cfg = DefaultConfig()
+	cfg.MaxMegapixels = math.Inf(1)
+	if err := cfg.Validate(); err == nil {
+		t.Error("infinite max_megapixels passed validation")
+	}
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("defaults are invalid: %v", err)
+	}
+}
+
+func TestSaveUnencodableValue(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := DefaultConfig()
+	cfg.MaxMegapixels = math.NaN()
+	if err := cfg.Save(); err == nil {
+		t.Error("saved a NaN setting")
+	}
+	if err := cfg.SelectProfile(""); err == nil {
+		t.Error("selected a profile over a NaN setting")
+	}
+}
+
+func TestSaveKeepsConcurrentEdits(t *testing.T) {
+	t.Setenv(EnvAPIKey, "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	first, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Concurrency = 7
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+	second.Suffix = "-small"
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Concurrency != 7 || cfg.Suffix != "-small" {
+		t.Errorf("got concurrency %d and suffix %q, want both edits", cfg.Concurrency, cfg.Suffix)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv(EnvAPIKey, "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	t.Setenv("TINITUI_CONCURRENCY", "3")
+	t.Setenv("TINITUI_HOOKS_TIMEOUT_SECONDS", "5")
+	t.Setenv("TINITUI_IGNORE", "a.png,b/")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Concurrency != 3 || cfg.Hooks.Timeout != 5 || len(cfg.Ignore) != 2 {
+		t.Errorf("env not applied: %+v", cfg)
+	}
+	if cfg.Origin("concurrency") != SourceEnv || cfg.Origin("hooks.timeout_seconds") != SourceEnv || cfg.Origin("hooks.on_complete") != SourceDefault {
+		t.Errorf("wrong origins: %v", cfg.origins)
+	}
+
+	t.Setenv("TINITUI_CONCURRENCY", "lots")
+	if _, err := Load(); err == nil {
+		t.Error("invalid env value accepted")
+	}
+	t.Setenv("TINITUI_CONCURRENCY", "3")
+	for _, v := range []string{"NaN", "Inf", "-2"} {
+		t.Setenv("TINITUI_MAX_MEGAPIXELS", v)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "TINITUI_MAX_MEGAPIXELS") {
+			t.Errorf("TINITUI_MAX_MEGAPIXELS=%s: got %v", v, err)
+		}
+	}
+}
+
+func TestAPIKeyCommand(t *testing.T) {
+	t.Setenv(EnvAPIKey, "")
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Chdir(t.TempDir())
+	path := filepath.Join(configHome, DirName, ConfigName)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"api_key_command": "echo from-helper"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "" {
+		t.Errorf("key resolved before ResolveAPIKey: %q", cfg.APIKey)
+	}
+	if err := cfg.ResolveAPIKey(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "from-helper" || cfg.Origin("api_key") != SourceCommand {
+		t.Errorf("got key %q from %s", cfg.APIKey, cfg.Origin("api_key"))
+	}
+
+	// The helper's key never ends up in the file
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Config
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.APIKey != "" {
+		t.Errorf("helper key written to config.json: %q", saved.APIKey)
+	}
+}
+
+func TestResolveAPIKeyPrecedence(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake secret-tool is a shell script")
+	}
+	// A secret-tool that always finds "from-store"
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "secret-tool"), []byte("#!/bin/sh\necho from-store\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Chdir(t.TempDir())
+
+	tests := []struct {
+		name   string
+		file   string
+		env    string
+		want   string
+		origin Source
+	}{
+		{"file", `{"api_key": "from-file"}`, "", "from-file", SourceGlobal},
+		{"store over file", `{"api_key": "from-file", "credential_store": "secret-service"}`, "", "from-store", "secret-service"},
+		{"command over store", `{"credential_store": "secret-service", "api_key_command": "echo from-command"}`, "", "from-command", SourceCommand},
+		{"env over all", `{"credential_store": "secret-service", "api_key_command": "echo from-command"}`, "from-env", "from-env", SourceEnv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvAPIKey, tt.env)
+			configHome := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", configHome)
+			path := filepath.Join(configHome, DirName, ConfigName)
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.ResolveAPIKey(); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.APIKey != tt.want || cfg.Origin("api_key") != tt.origin {
+				t.Errorf("got key %q from %s, want %q from %s", cfg.APIKey, cfg.Origin("api_key"), tt.want, tt.origin)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	t.Setenv(EnvAPIKey, "")
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Chdir(t.TempDir())
+
+	// An unversioned file in the old tinytui directory
+	legacy := filepath.Join(configHome, LegacyDirName, ConfigName)
+	if err := os.MkdirAll(filepath.Dir(legacy), 0700); err != nil {
+		t.Fatal(err)
+	}
+	old := `{"suffix": ".min", "colour": "blue", "hooks": {"timeout": 5}}`
+	if err := os.WriteFile(legacy, []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(configHome, DirName, ConfigName)
+	if cfg.Path() != path || cfg.Suffix != ".min" {
+		t.Errorf("legacy config not picked up: %s %+v", cfg.Path(), cfg)
+	}
+	want := []string{
+		path + `: unknown setting "colour" is ignored`,
+		path + `: unknown setting "hooks.timeout" is ignored`,
+	}
+	if !reflect.DeepEqual(cfg.Warnings(), want) {
+		t.Errorf("warnings = %q, want %q", cfg.Warnings(), want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]any
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved["version"] != float64(CurrentVersion) || saved["colour"] != "blue" {
+		t.Errorf("migrated file = %s", data)
+	}
+	if backup, err := os.ReadFile(path + ".v0.bak"); err != nil || string(backup) != old {
+		t.Errorf("backup = %q, %v", backup, err)
+	}
+
+	// Newer files are refused, invalid settings only reported so the
+	// commands fixing them still run
+	os.WriteFile(path, []byte(`{"version": 99}`), 0600)
+	if _, err := Load(); err == nil {
+		t.Error("newer config version accepted")
+	}
+	os.WriteFile(path, []byte(`{"version": 1, "output_mode": "directory"}`), 0600)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := cfg.Warnings(); len(w) != 1 || !strings.Contains(w[0], "output_dir") {
+		t.Errorf("warnings = %q", w)
+	}
+}
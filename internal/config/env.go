@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// EnvPrefix starts the environment variables overriding settings. The name
+// of each is derived from the JSON name: hooks.timeout_seconds is read from
+// TINITUI_HOOKS_TIMEOUT_SECONDS.
+const EnvPrefix = "TINITUI_"
+
+// EnvError reports an environment variable holding an invalid value.
+type EnvError struct {
+	Name string
+	Err  error
+}
+
+func (e *EnvError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e *EnvError) Unwrap() error {
+	return e.Err
+}
+
+// EnvName returns the environment variable overriding the setting key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// applyEnv applies environment overrides to the given settings, or to all of
+// them when keys is empty. Maps such as profiles can't be set this way.
+func (c *Config) applyEnv(keys ...string) error {
+	if len(keys) == 0 {
+		keys = Keys()
+		// The historical name, overridden by TINITUI_API_KEY
+		if envKey := os.Getenv(EnvAPIKey); envKey != "" {
+			c.APIKey = envKey
+			c.setOrigin("api_key", SourceEnv)
+		}
+	}
+	for _, key := range keys {
+		value, ok := os.LookupEnv(EnvName(key))
+		if !ok {
+			continue
+		}
+		if s, err := c.setting(key); err != nil || s.value.Kind() == reflect.Map {
+			continue
+		}
+		// The same rules as for config set, so nothing a user couldn't
+		// store gets in this way
+		if err := c.Set(key, value); err != nil {
+			return &EnvError{Name: EnvName(key), Err: err}
+		}
+		c.setOrigin(key, SourceEnv)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// setting is one leaf of Config addressed by its dotted JSON name, e.g.
+// "suffix" or "hooks.timeout_seconds".
+type setting struct {
+	key   string
+	value reflect.Value
+}
+
+// settings lists the leaves of v, a struct, in declaration order.
+func settings(v reflect.Value, prefix string) []setting {
+	var out []setting
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		// The schema version is managed by tinitui, not a setting
+		if name == "" || name == "-" || name == "version" {
+			continue
+		}
+		key := prefix + name
+		if f.Type.Kind() == reflect.Struct {
+			out = append(out, settings(v.Field(i), key+".")...)
+			continue
+		}
+		out = append(out, setting{key: key, value: v.Field(i)})
+	}
+	return out
+}
+
+func (c *Config) setting(key string) (setting, error) {
+	for _, s := range settings(reflect.ValueOf(c).Elem(), "") {
+		if s.key == key {
+			return s, nil
+		}
+	}
+	return setting{}, fmt.Errorf("unknown setting %q", key)
+}
+
+// Keys returns the dotted names of all settings in declaration order.
+func Keys() []string {
+	var keys []string
+	for _, s := range settings(reflect.ValueOf(DefaultConfig()).Elem(), "") {
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+// Get returns a setting formatted for display: lists are comma separated
+// and maps are JSON.
+func (c *Config) Get(key string) (string, error) {
+	s, err := c.setting(key)
+	if err != nil {
+		return "", err
+	}
+	v := s.value
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ","), nil
+	case reflect.Map:
+		if v.Len() == 0 {
+			return "", nil
+		}
+		data, err := json.Marshal(v.Interface())
+		return string(data), err
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// Set parses value according to the type of the setting and stores it.
+// Lists take comma separated items.
+func (c *Config) Set(key, value string) error {
+	s, err := c.setting(key)
+	if err != nil {
+		return err
+	}
+	v := s.value
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected true or false, got %q", key, value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: expected a whole number, got %q", key, value)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: expected a number, got %q", key, value)
+		}
+		if err := checkFloat(key, f); err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("%s can't be set from the command line, edit the config file instead", key)
+	}
+	return nil
+}
+
+// checkFloat rejects values no float setting can take: all of them are
+// sizes or limits, and JSON can't hold NaN or infinity.
+func checkFloat(key string, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f < 0 {
+		return fmt.Errorf("%s: expected a finite number of at least 0, got %v", key, f)
+	}
+	return nil
+}
+
+// Unset restores a setting to its default value.
+func (c *Config) Unset(key string) error {
+	s, err := c.setting(key)
+	if err != nil {
+		return err
+	}
+	def, _ := DefaultConfig().setting(key)
+	s.value.Set(def.value)
+	return nil
+}
+
+// GlobalPath returns the location of the global config file, moving a
+// config directory left by tinytui releases into place first.
+func GlobalPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if err := MigrateLegacyDir(configDir); err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, DirName, ConfigName), nil
+}
+
+// LoadGlobal reads only the global config file over the defaults, without
+// project files, profiles or environment variables. It is what commands
+// changing the stored settings work on.
+func LoadGlobal() (*Config, error) {
+	path, err := GlobalPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg := DefaultConfig()
+	cfg.configPath = path
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := cfg.applyGlobal(path, data); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Path returns the global config file the settings are saved to.
+func (c *Config) Path() string {
+	return c.configPath
+}
+
+// Validate checks settings whose type alone doesn't make them valid and
+// returns all problems found.
+func (c *Config) Validate() error {
+	var errs []error
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %q is not one of %s", key, value, strings.Join(allowed, ", ")))
+	}
+
+	oneOf("output_mode", c.OutputMode, "replace", "directory")
+	if c.OutputMode == "directory" && c.OutputDir == "" {
+		errs = append(errs, errors.New("output_dir: required when output_mode is directory"))
+	}
+	if c.CredentialStore != "" {
+		oneOf("credential_store", c.CredentialStore, CredentialStoreFile, CredentialStoreSecretService)
+	}
+	oneOf("mascot", string(c.Mascot), string(MascotOff), string(MascotOn), string(MascotAuto))
+	oneOf("mascot_type", c.MascotType, "panda", "waifu1", "waifu2")
+	oneOf("on_locked", c.OnLocked, OnLockedSkip, OnLockedWait)
+	oneOf("animated", c.Animated, AnimatedCompress, AnimatedSkip, AnimatedError)
+	if c.Concurrency < 1 || c.Concurrency > 4 {
+		errs = append(errs, fmt.Errorf("concurrency: %d is not between 1 and 4", c.Concurrency))
+	}
+	if c.MinBytes < 0 || c.MaxBytes < 0 {
+		errs = append(errs, errors.New("min_bytes and max_bytes can't be negative"))
+	}
+	for _, s := range settings(reflect.ValueOf(c).Elem(), "") {
+		if s.value.Kind() == reflect.Float64 {
+			if err := checkFloat(s.key, s.value.Float()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if c.MaxBytes > 0 && c.MinBytes > c.MaxBytes {
+		errs = append(errs, fmt.Errorf("min_bytes: %d is larger than max_bytes %d", c.MinBytes, c.MaxBytes))
+	}
+	if c.History.MaxAgeDays < 0 || c.History.MaxRecords < 0 || c.History.MaxBytes < 0 {
+		errs = append(errs, errors.New("history limits can't be negative"))
+	}
+	if c.Hooks.Timeout < 0 {
+		errs = append(errs, errors.New("hooks.timeout_seconds: can't be negative"))
+	}
+	if c.Profile != "" {
+		if _, ok := c.Profiles[c.Profile]; !ok {
+			errs = append(errs, fmt.Errorf("profile: %q is not defined in profiles", c.Profile))
+		}
+	}
+	for _, name := range c.ProfileNames() {
+		p := c.Profiles[name]
+		if p.OutputMode != nil {
+			oneOf("profiles."+name+".output_mode", *p.OutputMode, "replace", "directory")
+		}
+		if p.Concurrency != nil && (*p.Concurrency < 1 || *p.Concurrency > 4) {
+			errs = append(errs, fmt.Errorf("profiles.%s.concurrency: %d is not between 1 and 4", name, *p.Concurrency))
+		}
+	}
+	return errors.Join(errs...)
+}
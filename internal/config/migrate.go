@@ -0,0 +1,164 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gmsakibursabbir/tinitui/internal/atomicfile"
+	"github.com/gmsakibursabbir/tinitui/internal/lock"
+)
+
+// CurrentVersion is the schema version written to config files.
+const CurrentVersion = 1
+
+// LegacyDirName is the directory name used by releases published as tinytui.
+const LegacyDirName = "tinytui"
+
+// migrations[i] upgrades a file from version i to i+1, working on the raw
+// JSON object so renamed settings can be carried over before decoding.
+var migrations = []func(m map[string]any) error{
+	// 0 -> 1: unversioned files already have the version 1 layout
+	func(m map[string]any) error { return nil },
+}
+
+// MigrateLegacyDir renames parent/tinytui to parent/tinitui when only the
+// old directory exists, so installs from before the rename keep their
+// settings and history.
+func MigrateLegacyDir(parent string) error {
+	dir := filepath.Join(parent, DirName)
+	legacy := filepath.Join(parent, LegacyDirName)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return nil
+	}
+	if info, err := os.Stat(legacy); err != nil || !info.IsDir() {
+		return nil
+	}
+	if err := os.Rename(legacy, dir); err != nil {
+		return fmt.Errorf("moving %s to %s: %w", legacy, dir, err)
+	}
+	return nil
+}
+
+// migrate upgrades the global config file at path to CurrentVersion and
+// returns its contents. The file is rewritten, keeping a backup of the
+// original, only when a migration ran; this happens under the lock Save
+// takes, and the file is replaced atomically.
+func migrate(path string, data []byte) ([]byte, error) {
+	m, version, err := decodeVersioned(data)
+	if err != nil || version == CurrentVersion {
+		return data, err
+	}
+
+	l, err := lock.Acquire(context.Background(), path+".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	// Another process may have migrated or saved it meanwhile
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+	if m, version, err = decodeVersioned(data); err != nil || version == CurrentVersion {
+		return data, err
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		if err := migrations[v](m); err != nil {
+			return nil, fmt.Errorf("migrating from version %d: %w", v, err)
+		}
+	}
+	m["version"] = CurrentVersion
+	migrated, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if err := atomicfile.WriteFile(backup, data, PermFile); err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(path, migrated, PermFile); err != nil {
+		return nil, err
+	}
+	return migrated, nil
+}
+
+// decodeVersioned decodes a config file and returns its schema version,
+// rejecting files from newer releases.
+func decodeVersioned(data []byte) (map[string]any, int, error) {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, 0, err
+	}
+	version := 0
+	if v, ok := m["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > CurrentVersion {
+		return nil, 0, fmt.Errorf("written by a newer tinitui (config version %d, this release understands %d)", version, CurrentVersion)
+	}
+	return m, version, nil
+}
+
+// unknownKeys lists the keys of a JSON object that don't match a field of
+// t, descending into nested objects and maps of objects.
+func unknownKeys(t reflect.Type, data any, prefix string) []string {
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return nil
+	}
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+			fields[name] = f.Type
+		}
+	}
+
+	var unknown []string
+	for key, value := range obj {
+		ft, ok := fields[key]
+		if !ok {
+			unknown = append(unknown, prefix+key)
+			continue
+		}
+		switch {
+		case ft.Kind() == reflect.Struct:
+			unknown = append(unknown, unknownKeys(ft, value, prefix+key+".")...)
+		case ft.Kind() == reflect.Map && ft.Elem().Kind() == reflect.Struct:
+			if entries, ok := value.(map[string]any); ok {
+				for name, entry := range entries {
+					unknown = append(unknown, unknownKeys(ft.Elem(), entry, prefix+key+"."+name+".")...)
+				}
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// checkUnknown records a warning for every unknown setting in a config file.
+func (c *Config) checkUnknown(path string, data []byte) {
+	var m map[string]any
+	if json.Unmarshal(data, &m) != nil {
+		return
+	}
+	for _, key := range unknownKeys(reflect.TypeOf(Config{}), m, "") {
+		c.warnings = append(c.warnings, fmt.Sprintf("%s: unknown setting %q is ignored", path, key))
+	}
+}
+
+// Warnings returns problems found while loading that didn't stop it, such
+// as unknown settings.
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Profile bundles output settings that are switched together, e.g. "web"
+// for blog images and "store" for app store screenshots. Unset fields keep
+// their value from the other sources.
+type Profile struct {
+	OutputMode  *string `json:"output_mode,omitempty"`
+	OutputDir   *string `json:"output_dir,omitempty"`
+	Suffix      *string `json:"suffix,omitempty"`
+	Metadata    *bool   `json:"metadata,omitempty"`
+	Concurrency *int    `json:"concurrency,omitempty"`
+}
+
+// profileKeys are the JSON names of the settings a profile can set.
+var profileKeys = []string{"output_mode", "output_dir", "suffix", "metadata", "concurrency"}
+
+// ProfileNames returns the configured profiles in alphabetical order.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectProfile applies the named profile on top of the global and project
+// settings, replacing the previously active one. An empty name deselects
+// the profile. Settings changed by switching are not written to the global
+// file by Save, only the profile name is.
+func (c *Config) SelectProfile(name string) error {
+	var p Profile
+	if name != "" {
+		var ok bool
+		if p, ok = c.Profiles[name]; !ok {
+			if len(c.Profiles) == 0 {
+				return fmt.Errorf("unknown profile %q: no profiles configured", name)
+			}
+			return fmt.Errorf("unknown profile %q (have %s)", name, strings.Join(c.ProfileNames(), ", "))
+		}
+	}
+
+	if c.base == nil {
+		base, err := c.snapshot()
+		if err != nil {
+			return err
+		}
+		c.base = base
+		c.baseOrigins = make(map[string]Source, len(c.origins))
+		for k, src := range c.origins {
+			c.baseOrigins[k] = src
+		}
+	}
+
+	// Undo the previous profile
+	cur := reflect.ValueOf(c).Elem()
+	base := reflect.ValueOf(c.base).Elem()
+	for _, key := range profileKeys {
+		i, err := fieldIndex(key)
+		if err != nil {
+			return err
+		}
+		cur.Field(i).Set(base.Field(i))
+		if src, ok := c.baseOrigins[key]; ok {
+			c.origins[key] = src
+		} else {
+			delete(c.origins, key)
+		}
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := c.apply(data, SourceProfile); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	c.Profile = name
+	// Environment variables still win over the profile
+	if err := c.applyEnv(profileKeys...); err != nil {
+		return err
+	}
+
+	// Treat the profile like a project override so Save keeps global values
+	if c.loaded != nil {
+		loaded := reflect.ValueOf(c.loaded).Elem()
+		for _, key := range profileKeys {
+			i, err := fieldIndex(key)
+			if err != nil {
+				return err
+			}
+			loaded.Field(i).Set(cur.Field(i))
+		}
+	}
+	return nil
+}
+
+// fieldIndex returns the index of the Config field with the given JSON name.
+func fieldIndex(name string) (int, error) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); tag == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("config: no field %q", name)
+}
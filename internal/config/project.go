@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ProjectFileNames are looked for in the working directory and its parents.
+// The first one found applies, so a repository can commit its own output
+// conventions.
+var ProjectFileNames = []string{".tinitui.json", ".tinitui.yaml", ".tinitui.yml"}
+
+// projectForbidden are settings a project file may not set: a committed file
+// must neither carry credentials nor run commands on whoever checks it out,
+// nor prune the user's history.
+var projectForbidden = []string{"api_key", "api_key_command", "credential_store", "hooks", "history"}
+
+// FindProjectFile returns the nearest project file in dir or its parents,
+// or "" if there is none.
+func FindProjectFile(dir string) string {
+	for {
+		for _, name := range ProjectFileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ProjectDir returns the directory of the project file in effect, which
+// relative paths and ignore patterns in it are resolved against.
+func (c *Config) ProjectDir() string {
+	if c.projectPath == "" {
+		return ""
+	}
+	return filepath.Dir(c.projectPath)
+}
+
+// yamlToJSON converts a YAML project file to the JSON the settings are
+// decoded from. The document must be a mapping.
+func yamlToJSON(data []byte) ([]byte, error) {
+	values := map[string]any{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return json.Marshal(values)
+}
+
+// loadProject applies the project file at path on top of the current settings.
+func (c *Config) loadProject(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, k := range projectForbidden {
+		if _, ok := keys[k]; ok {
+			return fmt.Errorf("%s: %s can't be set in a project file", path, k)
+		}
+	}
+
+	if err := c.apply(data, SourceProject); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	// A relative output directory is relative to the project, not the cwd
+	if _, ok := keys["output_dir"]; ok && c.OutputDir != "" && !filepath.IsAbs(c.OutputDir) {
+		c.OutputDir = filepath.Join(filepath.Dir(path), c.OutputDir)
+	}
+	c.checkUnknown(path, data)
+	c.projectPath = path
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package credentials keeps the API key outside the plain-text config file.
+//
+// The Secret Service store needs the secret-tool command from libsecret
+// (libsecret-tools on Debian and Ubuntu, libsecret on Fedora and Arch) and
+// a running keyring daemon; without secret-tool it fails with ErrUnavailable
+// rather than falling back to the file.
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Service and Account identify the key in the OS secret store.
+const (
+	Service = "tinitui"
+	Account = "api_key"
+)
+
+// ErrNotFound is returned by Get when no key is stored.
+var ErrNotFound = errors.New("no API key stored")
+
+// ErrReadOnly is returned by stores that can't be written to.
+var ErrReadOnly = errors.New("credential store is read-only")
+
+// ErrUnavailable is returned when the program a store relies on isn't
+// installed.
+var ErrUnavailable = errors.New("credential store unavailable")
+
+// secretTool is the libsecret command line client.
+const secretTool = "secret-tool"
+
+// commandTimeout bounds helpers, which may ask for a passphrase.
+const commandTimeout = 2 * time.Minute
+
+// Store holds one secret, the TinyPNG API key. The config file itself is the
+// fallback used when no store is configured.
+type Store interface {
+	Name() string
+	Get() (string, error)
+	Set(secret string) error
+	Delete() error
+}
+
+// SecretService stores the key in the freedesktop Secret Service (GNOME
+// Keyring, KWallet) through libsecret's secret-tool, which speaks D-Bus.
+func SecretService() Store {
+	return secretService{}
+}
+
+type secretService struct{}
+
+func (secretService) Name() string { return "secret-service" }
+
+func (s secretService) Get() (string, error) {
+	out, err := s.run(nil, "lookup", "service", Service, "account", Account)
+	if err != nil {
+		// secret-tool exits 1 without output when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(out) == 0 {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", ErrNotFound
+	}
+	return key, nil
+}
+
+func (s secretService) Set(secret string) error {
+	_, err := s.run(strings.NewReader(secret), "store", "--label=tinitui API key", "service", Service, "account", Account)
+	return err
+}
+
+func (s secretService) Delete() error {
+	_, err := s.run(nil, "clear", "service", Service, "account", Account)
+	return err
+}
+
+func (secretService) run(stdin *strings.Reader, args ...string) ([]byte, error) {
+	path, err := exec.LookPath(secretTool)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s not found; install libsecret-tools (Debian, Ubuntu) or libsecret (Fedora, Arch), or use 'tinitui config set credential_store file' and set the key again",
+			ErrUnavailable, secretTool)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("secret-tool %s: %w: %s", args[0], err, msg)
+		}
+		return out, fmt.Errorf("secret-tool %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// Command reads the key from the first line printed by a shell command such
+// as "pass show tinify". The user's terminal stays attached so the helper can
+// ask for a passphrase.
+func Command(command string) Store {
+	return commandStore{command: command}
+}
+
+type commandStore struct {
+	command string
+}
+
+func (commandStore) Name() string { return "api_key_command" }
+
+func (s commandStore) Get() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", s.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", s.command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("api_key_command failed: %w", err)
+	}
+	key, _, _ := strings.Cut(string(out), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("api_key_command printed no key")
+	}
+	return key, nil
+}
+
+func (commandStore) Set(string) error { return ErrReadOnly }
+
+func (commandStore) Delete() error { return ErrReadOnly }
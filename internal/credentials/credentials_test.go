@@ -0,0 +1,90 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCommandStore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands run through sh")
+	}
+	tests := []struct {
+		command string
+		want    string
+		wantErr bool
+	}{
+		{"printf '  key-1  \\nsecond line\\n'", "key-1", false},
+		{"true", "", true},
+		{"echo key-2; exit 3", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Command(tt.command).Get()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Get(%q) = %q, %v", tt.command, got, err)
+		}
+	}
+
+	s := Command("echo key")
+	if err := s.Set("other"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Set = %v, want ErrReadOnly", err)
+	}
+	if err := s.Delete(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete = %v, want ErrReadOnly", err)
+	}
+}
+
+// fakeSecretTool puts on PATH a secret-tool keeping its secret in a file.
+func fakeSecretTool(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake secret-tool is a shell script")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+secret="$(dirname "$0")/secret"
+case "$1" in
+lookup) [ -f "$secret" ] || exit 1; cat "$secret" ;;
+store) cat > "$secret" ;;
+clear) rm -f "$secret" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSecretService(t *testing.T) {
+	fakeSecretTool(t)
+	s := SecretService()
+
+	if _, err := s.Get(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get on an empty store = %v, want ErrNotFound", err)
+	}
+	if err := s.Set("key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get(); got != "key-1" || err != nil {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	if err := s.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSecretServiceWithoutSecretTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := SecretService().Get(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Get = %v, want ErrUnavailable", err)
+	}
+	if err := SecretService().Set("key"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Set = %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,70 @@
+package history
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// What the history knows about a file on disk.
+const (
+	FileCompressed = "compressed" // Its content is the result (or source) of a compression
+	FileChanged    = "changed"    // It was compressed, but its content differs since
+	FileNew        = "new"        // It was never compressed
+)
+
+// Known indexes successful records by content hash and path to tell whether
+// a file on disk has been compressed.
+type Known struct {
+	hashes map[string]bool    // Outputs, and inputs compressed to a separate file
+	paths  map[string]*Record // Latest success per input and output path
+}
+
+// NewKnown indexes records, e.g. All followed by Archived.
+func NewKnown(records []*Record) *Known {
+	k := &Known{hashes: make(map[string]bool), paths: make(map[string]*Record)}
+	for _, r := range records {
+		if r.Status != StatusSuccess {
+			continue
+		}
+		if r.OutputSHA256 != "" {
+			k.hashes[r.OutputSHA256] = true
+		}
+		// An original kept next to its compressed copy is dealt with; one
+		// compressed in place and restored since is not
+		if r.InputSHA256 != "" && r.Output != "" && r.Output != r.File {
+			k.hashes[r.InputSHA256] = true
+		}
+		for _, p := range []string{r.File, r.Output} {
+			if p == "" {
+				continue
+			}
+			p = filepath.Clean(p)
+			if prev := k.paths[p]; prev == nil || r.Timestamp.After(prev.Timestamp) {
+				k.paths[p] = r
+			}
+		}
+	}
+	return k
+}
+
+// State returns FileCompressed, FileChanged or FileNew for the file at path
+// with the given SHA-256 and modification time. Records written before
+// hashes were recorded are matched by path and time instead.
+func (k *Known) State(path, sum string, modified time.Time) string {
+	if k.hashes[sum] {
+		return FileCompressed
+	}
+	r := k.paths[filepath.Clean(path)]
+	if r == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			r = k.paths[abs]
+		}
+	}
+	switch {
+	case r == nil:
+		return FileNew
+	case r.OutputSHA256 == "" && !modified.After(r.Timestamp):
+		return FileCompressed
+	}
+	return FileChanged
+}
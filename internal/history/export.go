@@ -0,0 +1,163 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns lists every field exports can include, named as in the JSON
+// records, in declaration order.
+var Columns = recordColumns()
+
+// DefaultColumns are exported when no columns are chosen, in the order of
+// the CSV export of earlier releases.
+var DefaultColumns = []string{"file", "before_size", "after_size", "saved_bytes", "saved_percent", "status", "timestamp", "error"}
+
+// defaultHeader names DefaultColumns as earlier releases did, so existing
+// spreadsheets and scripts keep working.
+var defaultHeader = []string{"File", "Before_Size", "After_Size", "Saved_Bytes", "Saved_Percent", "Status", "Timestamp", "Error"}
+
+// columnIndex maps a column name to its Record field.
+var columnIndex = func() map[string]int {
+	idx := make(map[string]int)
+	t := reflect.TypeOf(Record{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		idx[name] = i
+	}
+	return idx
+}()
+
+func recordColumns() []string {
+	var cols []string
+	t := reflect.TypeOf(Record{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		cols = append(cols, name)
+	}
+	return cols
+}
+
+// CheckColumns returns an error naming the first unknown column.
+func CheckColumns(columns []string) error {
+	for _, c := range columns {
+		if _, ok := columnIndex[c]; !ok {
+			return fmt.Errorf("unknown column %q: use %s", c, strings.Join(Columns, ", "))
+		}
+	}
+	return nil
+}
+
+func columnValue(r *Record, column string) any {
+	return reflect.ValueOf(r).Elem().Field(columnIndex[column]).Interface()
+}
+
+// cell formats a value for CSV and TSV: times as RFC 3339, percentages with
+// two decimals.
+func cell(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// WriteCSV writes records as RFC 4180 CSV with a header row of column names.
+// Nil columns means DefaultColumns, under their historical header.
+func WriteCSV(w io.Writer, records []*Record, columns []string) error {
+	return writeDelimited(w, records, columns, ',')
+}
+
+// WriteTSV is WriteCSV with tabs between fields.
+func WriteTSV(w io.Writer, records []*Record, columns []string) error {
+	return writeDelimited(w, records, columns, '\t')
+}
+
+func writeDelimited(w io.Writer, records []*Record, columns []string, comma rune) error {
+	header := columns
+	if columns == nil {
+		columns, header = DefaultColumns, defaultHeader
+	}
+	if err := CheckColumns(columns); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	row := make([]string, len(columns))
+	for _, r := range records {
+		for i, c := range columns {
+			row[i] = cell(columnValue(r, c))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteNDJSON writes one JSON object per record and line. Nil columns
+// writes whole records.
+func WriteNDJSON(w io.Writer, records []*Record, columns []string) error {
+	if err := CheckColumns(columns); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		var v any = r
+		if columns != nil {
+			obj := make(map[string]any, len(columns))
+			for _, c := range columns {
+				obj[c] = columnValue(r, c)
+			}
+			v = obj
+		}
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes v, e.g. records or groups, as an indented JSON document.
+func WriteJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (m *Manager) ExportCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteCSV(f, m.All(), nil); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (m *Manager) ExportJSON(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteJSON(f, m.All()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,51 +1,99 @@
 package history
 
 import (
-	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/config"
 )
 
 const (
 	DirName     = "tinitui"
-	FileName    = "history.json"
+	FileName    = "history.jsonl" // One JSON record per line, appended to
+	LegacyFileName = "history.json" // JSON array written by older releases
 	PermDir     = 0755 // Config is 0700 but history can be 755 usually, but let's stick to user privacy if needed. standard state is 700 or 755.
 	PermFile    = 0644
 )
 
+// Record statuses.
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Where a run was started from.
+const (
+	SourceCLI = "cli"
+	SourceTUI = "tui"
+)
+
 type Record struct {
 	Timestamp      time.Time `json:"timestamp"`
+	Session        string    `json:"session,omitempty"` // Shared by all records of one run
+	Source         string    `json:"source,omitempty"`  // "cli" or "tui"
 	File           string    `json:"file"`
+	Output         string    `json:"output,omitempty"`
+	Format         string    `json:"format,omitempty"` // Detected format, e.g. "png"
+	Width          int       `json:"width,omitempty"`
+	Height         int       `json:"height,omitempty"`
 	BeforeSize     int64     `json:"before_size"`
 	AfterSize      int64     `json:"after_size"`
 	SavedBytes     int64     `json:"saved_bytes"`
 	SavedPercent   float64   `json:"saved_percent"`
-	Status         string    `json:"status"` // "success", "failed"
-	Error          string    `json:"error,omitempty"`
+	InputSHA256    string    `json:"input_sha256,omitempty"`
+	OutputSHA256   string    `json:"output_sha256,omitempty"`
+	KeyAlias       string    `json:"key_alias,omitempty"` // Last characters of the API key used
+	DurationMS     int64     `json:"duration_ms,omitempty"`
+	Retries        int       `json:"retries,omitempty"`
+	Status         string    `json:"status"` // "success", "failed" or "skipped"
+	Error          string    `json:"error,omitempty"` // Failure or skip reason
 }
 
 type Manager struct {
-	records []*Record
-	mu      sync.RWMutex
-	path    string
+	records  []*Record
+	pending  []*Record // Added since the last Flush, not yet on disk
+	damaged  bool      // The log has unreadable lines; the next Flush compacts it
+	flushErr error     // First error of a flush triggered by Add
+	retention config.History
+	mu       sync.RWMutex
+	path     string
 }
 
-func New() (*Manager, error) {
+// StateDir returns the directory holding history and other runtime state.
+func StateDir() (string, error) {
 	// os.UserStateDir was added in Go 1.21, but if environment is older or issue exists:
 	// Use manual construction: ~/.local/state
 	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	parent := filepath.Join(home, ".local", "state")
+	// Best effort: without the old directory history just starts empty
+	config.MigrateLegacyDir(parent)
+	return filepath.Join(parent, DirName), nil
+}
+
+func New() (*Manager, error) {
+	stateDir, err := StateDir()
 	if err != nil {
 		return nil, err
 	}
-	stateDir := filepath.Join(home, ".local", "state")
-	
-	path := filepath.Join(stateDir, DirName, FileName)
+	return Open(filepath.Join(stateDir, FileName))
+}
+
+// Open reads the history log at path, converting a history.json written by
+// older releases next to it first.
+func Open(path string) (*Manager, error) {
 	m := &Manager{
 		path: path,
 	}
+	if err := m.migrateLegacy(); err != nil {
+		return nil, err
+	}
 	if err := m.Load(); err != nil {
 		// Only return error if it's NOT just file missing
 		if !os.IsNotExist(err) {
@@ -55,44 +103,18 @@ func New() (*Manager, error) {
 	return m, nil
 }
 
-func (m *Manager) Load() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	data, err := os.ReadFile(m.path)
-	if err != nil {
-		return err
-	}
-	return json.Unmarshal(data, &m.records)
-}
-
-func (m *Manager) Save() error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	
-	dir := filepath.Dir(m.path)
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return err
-	}
-	
-	data, err := json.MarshalIndent(m.records, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(m.path, data, PermFile)
-}
-
+// Add records r. Records are buffered and appended to the log in batches;
+// call Close (or Flush) before exiting so the last ones aren't lost.
 func (m *Manager) Add(r *Record) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.records = append(m.records, r)
-	// We might want to auto-save or flush periodically.
-	// For simplicity, let's auto-save on Add? Or caller manages Save().
-	// Prompt says "Save history to: ~/.local/state/tinytui/history.json".
-	// Let's autosave for CLI usage safety.
-	// Launch goroutine to save implementation detail? No, keep simple. 
-	// Just ignore error in Add for now or log it?
-	go m.Save()
+	m.pending = append(m.pending, r)
+	if len(m.pending) >= flushBatch {
+		if err := m.flushLocked(); err != nil && m.flushErr == nil {
+			m.flushErr = err
+		}
+	}
 }
 
 func (m *Manager) All() []*Record {
@@ -103,33 +125,55 @@ func (m *Manager) All() []*Record {
 	return res
 }
 
-func (m *Manager) ExportCSV(path string) error {
+// CompressionRatios returns the historical after/before size ratio of
+// successful records, keyed by lower-case file extension without the dot
+// ("jpeg" is folded into "jpg"). The "*" key holds the ratio across all formats.
+func (m *Manager) CompressionRatios() map[string]float64 {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	
-	f, err := os.Create(path)
-	if err != nil {
-		return err
+	return Ratios(m.records)
+}
+
+// Ratios is CompressionRatios over the given records.
+func Ratios(records []*Record) map[string]float64 {
+	before := make(map[string]int64)
+	after := make(map[string]int64)
+	for _, r := range records {
+		if r.Status != StatusSuccess || r.BeforeSize <= 0 {
+			continue
+		}
+		for _, key := range []string{FormatOf(r.File), "*"} {
+			before[key] += r.BeforeSize
+			after[key] += r.AfterSize
+		}
 	}
-	defer f.Close()
-	
-	// Write Header
-	// File, Before, After, Saved, %, Status, Time
-	fmt.Fprintln(f, "File,Before_Size,After_Size,Saved_Bytes,Saved_Percent,Status,Timestamp,Error")
-	
-	for _, r := range m.records {
-		fmt.Fprintf(f, "%q,%d,%d,%d,%.2f,%s,%s,%q\n",
-			r.File, r.BeforeSize, r.AfterSize, r.SavedBytes, r.SavedPercent, r.Status, r.Timestamp.Format(time.RFC3339), r.Error)
+
+	ratios := make(map[string]float64, len(before))
+	for key, b := range before {
+		ratios[key] = float64(after[key]) / float64(b)
 	}
-	return nil
+	return ratios
 }
 
-func (m *Manager) ExportJSON(path string) error {
+// LastCompressed returns, per file, when it was last compressed successfully.
+func (m *Manager) LastCompressed() map[string]time.Time {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	data, err := json.MarshalIndent(m.records, "", "  ")
-	if err != nil {
-		return err
+
+	last := make(map[string]time.Time)
+	for _, r := range m.records {
+		if r.Status == StatusSuccess && r.Timestamp.After(last[r.File]) {
+			last[r.File] = r.Timestamp
+		}
+	}
+	return last
+}
+
+// FormatOf returns the normalised format key used by CompressionRatios for path.
+func FormatOf(path string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "jpeg" {
+		return "jpg"
 	}
-	return os.WriteFile(path, data, 0644)
+	return ext
 }
@@ -0,0 +1,203 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/config"
+)
+
+func TestLogAppendAndCompact(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+
+	// Older releases wrote a JSON array
+	legacy := []*Record{{File: "old.png", Status: "success"}}
+	data, _ := json.Marshal(legacy)
+	if err := os.WriteFile(filepath.Join(dir, LegacyFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LegacyFileName)); !os.IsNotExist(err) {
+		t.Error("legacy history.json not removed")
+	}
+	for i := 0; i < flushBatch+1; i++ {
+		m.Add(&Record{Timestamp: time.Now(), File: "a.png", Status: "success"})
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash mid-append
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"file": "cut`)
+	f.Close()
+
+	m, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.All()); got != flushBatch+2 {
+		t.Fatalf("read %d records, want %d", got, flushBatch+2)
+	}
+	m.Add(&Record{File: "b.png", Status: "success"})
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, damaged, err := readLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if damaged || len(records) != flushBatch+3 || records[len(records)-1].File != "b.png" {
+		t.Errorf("after compaction: damaged=%v, %d records", damaged, len(records))
+	}
+}
+
+func TestQueryAndAggregate(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.Local) }
+	records := []*Record{
+		{Timestamp: day(1), File: "/img/a.png", Status: StatusSuccess, BeforeSize: 100, AfterSize: 40, SavedBytes: 60, Session: "s1"},
+		{Timestamp: day(2), File: "/img/b.jpg", Status: StatusFailed, Session: "s2"},
+		{Timestamp: day(3), File: "/other/c.png", Status: StatusSuccess, BeforeSize: 200, AfterSize: 100, SavedBytes: 100, Session: "s2"},
+	}
+
+	got, err := Filter(records, Query{Since: day(2), PathPrefix: "/img"})
+	if err != nil || len(got) != 1 || got[0].File != "/img/b.jpg" {
+		t.Errorf("since+prefix: %v, %v", got, err)
+	}
+	got, _ = Filter(records, Query{Session: "s2", Sort: "saved", Reverse: true, Limit: 1})
+	if len(got) != 1 || got[0].File != "/other/c.png" {
+		t.Errorf("session+sort+limit: %v", got)
+	}
+	got, _ = Filter(records, Query{Limit: 2})
+	if len(got) != 2 || got[0].File != "/other/c.png" || got[1].File != "/img/b.jpg" {
+		t.Errorf("limit keeps the newest records first: %v", got)
+	}
+	if got, _ = Filter(records, Query{PathPrefix: "/im"}); len(got) != 0 {
+		t.Errorf("prefix matched inside a path segment: %v", got)
+	}
+	if _, err := Filter(records, Query{Status: "done"}); err == nil {
+		t.Error("unknown status accepted")
+	}
+
+	groups, err := Aggregate(records, "format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Group{
+		{Key: "jpg", Records: 1, Failed: 1},
+		{Key: "png", Records: 2, Success: 2, BeforeSize: 300, AfterSize: 140, SavedBytes: 160, SavedPercent: 160.0 / 300 * 100},
+	}
+	if len(groups) != 2 || groups[0] != want[0] || groups[1] != want[1] {
+		t.Errorf("groups = %+v", groups)
+	}
+}
+
+func TestRetentionAndPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetRetention(config.History{MaxRecords: 3})
+	for d := 1; d <= 5; d++ {
+		m.Add(&Record{Timestamp: time.Date(2024, time.Month(d), 1, 0, 0, 0, 0, time.UTC), File: "a.png", Status: StatusSuccess, BeforeSize: 10, SavedBytes: 4})
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.All()); got != 3 {
+		t.Errorf("%d live records, want 3", got)
+	}
+
+	n, err := m.Prune(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || n != 2 {
+		t.Fatalf("pruned %d, %v", n, err)
+	}
+	archived, err := m.Archived()
+	if err != nil || len(archived) != 4 || archived[0].Timestamp.Month() != time.January {
+		t.Errorf("archived %d records, %v", len(archived), err)
+	}
+	got, err := m.Query(Query{Archived: true})
+	if err != nil || len(got) != 5 {
+		t.Errorf("query with archives: %d records, %v", len(got), err)
+	}
+
+	// Totals survive the archives being deleted
+	os.RemoveAll(filepath.Join(filepath.Dir(path), ArchiveDir))
+	life, err := m.Lifetime()
+	if err != nil || life.Records != 5 || life.SavedBytes != 20 {
+		t.Errorf("lifetime %+v, %v", life, err)
+	}
+}
+
+func TestExports(t *testing.T) {
+	records := []*Record{{Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), File: `a, "b".png`, Status: StatusFailed, Error: "line1\nline2"}}
+
+	var buf strings.Builder
+	if err := WriteCSV(&buf, records, []string{"file", "error", "timestamp"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "file,error,timestamp\n\"a, \"\"b\"\".png\",\"line1\nline2\",2024-01-02T03:04:05Z\n"
+	if buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, records, nil); err != nil {
+		t.Fatal(err)
+	}
+	if header, _, _ := strings.Cut(buf.String(), "\n"); header != "File,Before_Size,After_Size,Saved_Bytes,Saved_Percent,Status,Timestamp,Error" {
+		t.Errorf("default CSV header = %q", header)
+	}
+
+	buf.Reset()
+	if err := WriteNDJSON(&buf, records, []string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "{\"status\":\"failed\"}\n" {
+		t.Errorf("NDJSON = %q", buf.String())
+	}
+	if err := WriteTSV(&buf, records, []string{"size"}); err == nil {
+		t.Error("unknown column accepted")
+	}
+}
+
+func TestKnownState(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.Local) }
+	k := NewKnown([]*Record{
+		{Timestamp: day(1), File: "in-place.png", Output: "in-place.png", InputSHA256: "orig1", OutputSHA256: "small1", Status: StatusSuccess},
+		{Timestamp: day(1), File: "a.png", Output: "a.tiny.png", InputSHA256: "orig2", OutputSHA256: "small2", Status: StatusSuccess},
+		{Timestamp: day(2), File: "legacy.png", Status: StatusSuccess},
+		{Timestamp: day(2), File: "failed.png", InputSHA256: "orig3", Status: StatusFailed},
+	})
+
+	tests := []struct {
+		path, sum string
+		modified  time.Time
+		want      string
+	}{
+		{"in-place.png", "small1", day(5), FileCompressed},
+		{"in-place.png", "orig1", day(5), FileChanged}, // Restored original
+		{"a.png", "orig2", day(5), FileCompressed},
+		{"a.tiny.png", "small2", day(5), FileCompressed},
+		{"legacy.png", "x", day(1), FileCompressed},
+		{"legacy.png", "x", day(3), FileChanged},
+		{"failed.png", "orig3", day(1), FileNew},
+		{"other.png", "y", day(1), FileNew},
+	}
+	for _, tt := range tests {
+		if got := k.State(tt.path, tt.sum, tt.modified); got != tt.want {
+			t.Errorf("State(%s, %s) = %s, want %s", tt.path, tt.sum, got, tt.want)
+		}
+	}
+}
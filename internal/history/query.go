@@ -0,0 +1,202 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Query selects and orders records. Zero fields don't filter.
+type Query struct {
+	Since      time.Time
+	Until      time.Time
+	Status     string // StatusSuccess, StatusFailed or StatusSkipped
+	PathPrefix string // Directory or file matched against the input path, also as an absolute path
+	Session    string
+	Sort       string // "time" (default, newest first), "file", "saved" or "size"
+	Reverse    bool
+	Limit      int  // Keep the first Limit records after sorting
+	Archived   bool // Also search records moved to the archives
+}
+
+// SortKeys are the values Query.Sort accepts.
+var SortKeys = []string{"time", "file", "saved", "size"}
+
+// GroupKeys are the values Aggregate accepts.
+var GroupKeys = []string{"day", "month", "dir", "format"}
+
+// Query returns the records matching q.
+func (m *Manager) Query(q Query) ([]*Record, error) {
+	records := m.All()
+	if q.Archived {
+		archived, err := m.Archived()
+		if err != nil {
+			return nil, err
+		}
+		records = append(archived, records...)
+	}
+	return Filter(records, q)
+}
+
+// Filter applies q to records, which are not modified.
+func Filter(records []*Record, q Query) ([]*Record, error) {
+	less, err := sortFunc(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	switch q.Status {
+	case "", StatusSuccess, StatusFailed, StatusSkipped:
+	default:
+		return nil, fmt.Errorf("unknown status %q: use %s, %s or %s", q.Status, StatusSuccess, StatusFailed, StatusSkipped)
+	}
+	prefixes := []string{q.PathPrefix}
+	if abs, err := filepath.Abs(q.PathPrefix); err == nil && q.PathPrefix != "" && abs != q.PathPrefix {
+		prefixes = append(prefixes, abs)
+	}
+
+	out := []*Record{}
+	for _, r := range records {
+		if !q.Since.IsZero() && r.Timestamp.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !r.Timestamp.Before(q.Until) {
+			continue
+		}
+		if q.Status != "" && r.Status != q.Status {
+			continue
+		}
+		if q.Session != "" && r.Session != q.Session {
+			continue
+		}
+		if q.PathPrefix != "" && !hasAnyPrefix(r.File, prefixes) {
+			continue
+		}
+		out = append(out, r)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if q.Reverse {
+			return less(out[j], out[i])
+		}
+		return less(out[i], out[j])
+	})
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+// hasAnyPrefix reports whether the path s is, or is inside, one of the
+// prefixes: "/img" matches "/img/a.png" but not "/images/a.png".
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if !strings.HasPrefix(s, p) {
+			continue
+		}
+		if len(s) == len(p) || os.IsPathSeparator(p[len(p)-1]) || os.IsPathSeparator(s[len(p)]) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortFunc(key string) (func(a, b *Record) bool, error) {
+	switch key {
+	case "", "time":
+		// Newest first, so a limit keeps the latest records
+		return func(a, b *Record) bool { return a.Timestamp.After(b.Timestamp) }, nil
+	case "file":
+		return func(a, b *Record) bool { return a.File < b.File }, nil
+	case "saved":
+		return func(a, b *Record) bool { return a.SavedBytes < b.SavedBytes }, nil
+	case "size":
+		return func(a, b *Record) bool { return a.BeforeSize < b.BeforeSize }, nil
+	}
+	return nil, fmt.Errorf("unknown sort key %q: use %s", key, strings.Join(SortKeys, ", "))
+}
+
+// Group totals the records sharing a key. Sizes only count successes.
+type Group struct {
+	Key          string  `json:"key"`
+	Records      int     `json:"records"`
+	Success      int     `json:"success"`
+	Failed       int     `json:"failed"`
+	Skipped      int     `json:"skipped"`
+	BeforeSize   int64   `json:"before_size"`
+	AfterSize    int64   `json:"after_size"`
+	SavedBytes   int64   `json:"saved_bytes"`
+	SavedPercent float64 `json:"saved_percent"`
+}
+
+// Aggregate groups records by day, month, dir or format, ordered by key.
+func Aggregate(records []*Record, by string) ([]Group, error) {
+	var keyOf func(r *Record) string
+	switch by {
+	case "day":
+		keyOf = func(r *Record) string { return r.Timestamp.Local().Format("2006-01-02") }
+	case "month":
+		keyOf = func(r *Record) string { return r.Timestamp.Local().Format("2006-01") }
+	case "dir":
+		keyOf = func(r *Record) string { return filepath.Dir(r.File) }
+	case "format":
+		keyOf = func(r *Record) string {
+			if r.Format != "" {
+				return r.Format
+			}
+			return FormatOf(r.File)
+		}
+	default:
+		return nil, fmt.Errorf("unknown grouping %q: use %s", by, strings.Join(GroupKeys, ", "))
+	}
+
+	groups := make(map[string]*Group)
+	for _, r := range records {
+		key := keyOf(r)
+		g, ok := groups[key]
+		if !ok {
+			g = &Group{Key: key}
+			groups[key] = g
+		}
+		g.add(r)
+	}
+
+	out := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		if g.BeforeSize > 0 {
+			g.SavedPercent = float64(g.SavedBytes) / float64(g.BeforeSize) * 100
+		}
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// Total sums all records into one group with an empty key.
+func Total(records []*Record) Group {
+	var g Group
+	for _, r := range records {
+		g.add(r)
+	}
+	if g.BeforeSize > 0 {
+		g.SavedPercent = float64(g.SavedBytes) / float64(g.BeforeSize) * 100
+	}
+	return g
+}
+
+func (g *Group) add(r *Record) {
+	g.Records++
+	switch r.Status {
+	case StatusSuccess:
+		g.Success++
+		g.BeforeSize += r.BeforeSize
+		g.AfterSize += r.AfterSize
+		g.SavedBytes += r.SavedBytes
+	case StatusFailed:
+		g.Failed++
+	case StatusSkipped:
+		g.Skipped++
+	}
+}
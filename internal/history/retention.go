@@ -0,0 +1,287 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/atomicfile"
+	"github.com/gmsakibursabbir/tinitui/internal/config"
+)
+
+const (
+	ArchiveDir = "archive"    // Monthly gzipped JSONL files, e.g. 2024-01.jsonl.gz
+	StatsName  = "stats.json" // Totals of all archived records
+)
+
+// SetRetention sets the limits applied to the log whenever it is flushed.
+func (m *Manager) SetRetention(r config.History) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retention = r
+}
+
+// overLimit reports whether the log exceeds a retention limit, judged from
+// what this Manager has read and written.
+func (m *Manager) overLimit() bool {
+	r := m.retention
+	if r.MaxRecords > 0 && len(m.records) > r.MaxRecords {
+		return true
+	}
+	if r.MaxAgeDays > 0 && len(m.records) > 0 && m.records[0].Timestamp.Before(maxAgeCutoff(r.MaxAgeDays)) {
+		return true
+	}
+	if r.MaxBytes > 0 {
+		if info, err := os.Stat(m.path); err == nil && info.Size() > r.MaxBytes {
+			return true
+		}
+	}
+	return false
+}
+
+func maxAgeCutoff(days int) time.Time {
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// applyRetention archives the oldest records until the log is within all
+// retention limits.
+func (m *Manager) applyRetention() error {
+	r := m.retention
+	_, err := m.pruneLocked(func(records []*Record) int {
+		n := 0
+		if r.MaxAgeDays > 0 {
+			n = max(n, countBefore(records, maxAgeCutoff(r.MaxAgeDays)))
+		}
+		if r.MaxRecords > 0 {
+			n = max(n, len(records)-r.MaxRecords)
+		}
+		if r.MaxBytes > 0 {
+			var size int64
+			for _, rec := range records {
+				size += encodedSize(rec)
+			}
+			for n < len(records) && size > r.MaxBytes {
+				size -= encodedSize(records[n])
+				n++
+			}
+		}
+		return n
+	})
+	return err
+}
+
+// countBefore counts the leading records older than cutoff. The log is in
+// the order records were added, which is chronological.
+func countBefore(records []*Record, cutoff time.Time) int {
+	n := 0
+	for n < len(records) && records[n].Timestamp.Before(cutoff) {
+		n++
+	}
+	return n
+}
+
+func encodedSize(r *Record) int64 {
+	data, _ := json.Marshal(r)
+	return int64(len(data)) + 1
+}
+
+// Prune moves records older than cutoff from the log to the monthly
+// archives and returns how many were moved.
+func (m *Manager) Prune(cutoff time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.flushLocked(); err != nil {
+		return 0, err
+	}
+	return m.pruneLocked(func(records []*Record) int {
+		return countBefore(records, cutoff)
+	})
+}
+
+// pruneLocked archives the first count(records) records of the log. The
+// archives and lifetime stats are written before the log is rewritten, so a
+// crash can at worst archive a record twice, never lose it.
+func (m *Manager) pruneLocked(count func([]*Record) int) (int, error) {
+	l, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer l.Release()
+
+	records, _, err := readLog(m.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := count(records)
+	if n == 0 {
+		return 0, nil
+	}
+	old, kept := records[:n], records[n:]
+
+	if err := m.archive(old); err != nil {
+		return 0, err
+	}
+	stats, err := m.archivedStats()
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range old {
+		stats.add(r)
+	}
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := atomicfile.WriteFile(m.statsPath(), data, PermFile); err != nil {
+		return 0, err
+	}
+
+	if err := writeLog(m.path, kept); err != nil {
+		return 0, err
+	}
+	m.records = append(kept, m.pending...)
+	m.damaged = false
+	return n, nil
+}
+
+func (m *Manager) archiveDir() string {
+	return filepath.Join(filepath.Dir(m.path), ArchiveDir)
+}
+
+func (m *Manager) statsPath() string {
+	return filepath.Join(filepath.Dir(m.path), StatsName)
+}
+
+// archive appends records to the file of their month. Each call adds a new
+// gzip member, which readers see as one continuous stream.
+func (m *Manager) archive(records []*Record) error {
+	byMonth := make(map[string][]*Record)
+	for _, r := range records {
+		month := r.Timestamp.UTC().Format("2006-01")
+		byMonth[month] = append(byMonth[month], r)
+	}
+	if err := os.MkdirAll(m.archiveDir(), 0700); err != nil {
+		return err
+	}
+	for month, recs := range byMonth {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		enc := json.NewEncoder(zw)
+		for _, r := range recs {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		path := filepath.Join(m.archiveDir(), month+".jsonl.gz")
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, PermFile)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(buf.Bytes())
+		if err == nil {
+			err = f.Sync()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Archived reads all archived records, oldest month first.
+func (m *Manager) Archived() ([]*Record, error) {
+	paths, err := filepath.Glob(filepath.Join(m.archiveDir(), "*.jsonl.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var records []*Record
+	for _, path := range paths {
+		recs, err := readArchive(path)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, recs...)
+	}
+	return records, nil
+}
+
+func readArchive(path string) ([]*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var records []*Record
+	r := bufio.NewReaderSize(zr, 64*1024)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec Record
+			if json.Unmarshal(line, &rec) == nil {
+				records = append(records, &rec)
+			}
+		}
+		// A member cut short by a crash ends the readable part of the file
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// archivedStats reads the totals of all archived records.
+func (m *Manager) archivedStats() (Group, error) {
+	var g Group
+	data, err := os.ReadFile(m.statsPath())
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return g, err
+	}
+	err = json.Unmarshal(data, &g)
+	return g, err
+}
+
+// Lifetime totals every record ever added, including archived ones, even if
+// the archives were deleted since.
+func (m *Manager) Lifetime() (Group, error) {
+	g, err := m.archivedStats()
+	if err != nil {
+		return g, err
+	}
+	for _, r := range m.All() {
+		g.add(r)
+	}
+	g.SavedPercent = 0
+	if g.BeforeSize > 0 {
+		g.SavedPercent = float64(g.SavedBytes) / float64(g.BeforeSize) * 100
+	}
+	return g, nil
+}
@@ -0,0 +1,237 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gmsakibursabbir/tinitui/internal/atomicfile"
+	"github.com/gmsakibursabbir/tinitui/internal/lock"
+)
+
+// flushBatch is how many records Add buffers before appending them.
+const flushBatch = 64
+
+// Load reads the log from disk, keeping records added but not yet flushed.
+func (m *Manager) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, damaged, err := readLog(m.path)
+	if err != nil {
+		return err
+	}
+	m.records = append(records, m.pending...)
+	m.damaged = damaged
+	return nil
+}
+
+// ReadLog returns the records of a JSONL history file, such as one written
+// by WriteNDJSON with all columns, without opening it for writing.
+func ReadLog(path string) ([]*Record, error) {
+	records, _, err := readLog(path)
+	return records, err
+}
+
+// readLog parses a JSONL history file. Lines that don't parse, such as one
+// cut short by a crash, are left out and reported as damage.
+func readLog(path string) ([]*Record, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	var records []*Record
+	damaged := false
+	r := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec Record
+			if json.Unmarshal(line, &rec) == nil {
+				records = append(records, &rec)
+			} else {
+				damaged = true
+			}
+		}
+		if err == io.EOF {
+			return records, damaged, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// Flush appends pending records to the log and applies the retention
+// limits. Appends hold an advisory lock on the history file so several
+// tinitui processes can add records at once.
+func (m *Manager) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.flushLocked()
+	if m.flushErr != nil {
+		err = errors.Join(m.flushErr, err)
+		m.flushErr = nil
+	}
+	return err
+}
+
+// Close flushes pending records. The Manager can still be read afterwards.
+func (m *Manager) Close() error {
+	return m.Flush()
+}
+
+func (m *Manager) flushLocked() error {
+	if err := m.appendLocked(); err != nil {
+		return err
+	}
+	if m.overLimit() {
+		return m.applyRetention()
+	}
+	return nil
+}
+
+// appendLocked writes pending records to the end of the log, or rewrites a
+// damaged log.
+func (m *Manager) appendLocked() error {
+	if len(m.pending) == 0 && !m.damaged {
+		return nil
+	}
+	if m.damaged {
+		return m.compactLocked()
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range m.pending {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	l, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	f, err := os.OpenFile(m.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, PermFile)
+	if err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	// A crash can leave the last line unterminated; don't glue onto it
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	m.pending = nil
+	return nil
+}
+
+// Compact rewrites the log as a snapshot of every readable record,
+// including pending ones, dropping damaged lines. The snapshot replaces the
+// log atomically.
+func (m *Manager) Compact() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.compactLocked()
+}
+
+func (m *Manager) compactLocked() error {
+	l, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	// Re-read so records appended by other processes are kept
+	records, _, err := readLog(m.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	records = append(records, m.pending...)
+	if err := writeLog(m.path, records); err != nil {
+		return err
+	}
+	m.records = records
+	m.pending = nil
+	m.damaged = false
+	return nil
+}
+
+// lock creates the state directory and takes the history file lock.
+func (m *Manager) lock() (*lock.Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return nil, err
+	}
+	return lock.Acquire(context.Background(), m.path+".lock")
+}
+
+// writeLog atomically replaces the log at path with records.
+func writeLog(path string, records []*Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return atomicfile.WriteFile(path, buf.Bytes(), PermFile)
+}
+
+// migrateLegacy converts the JSON array history of older releases into the
+// log and removes it.
+func (m *Manager) migrateLegacy() error {
+	legacy := filepath.Join(filepath.Dir(m.path), LegacyFileName)
+	data, err := os.ReadFile(legacy)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	l, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	// Another process may have converted it while we waited
+	if _, err := os.Stat(legacy); os.IsNotExist(err) {
+		return nil
+	}
+
+	var records []*Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+	current, _, err := readLog(m.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := writeLog(m.path, append(records, current...)); err != nil {
+		return err
+	}
+	return os.Remove(legacy)
+}
@@ -0,0 +1,77 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Hook event names, also exported to the command as TINITUI_HOOK.
+const (
+	BeforeCompress = "before_compress"
+	AfterCompress  = "after_compress"
+	OnFailure      = "on_failure"
+	OnComplete     = "on_complete"
+)
+
+const DefaultTimeout = 30 * time.Second
+
+// maxOutput caps how much of the hook's stderr ends up in error messages.
+const maxOutput = 512
+
+// Run executes command through the system shell. env is added to the current
+// environment and payload is written to stdin as JSON. The command is killed
+// once timeout elapses; a zero timeout means DefaultTimeout.
+func Run(ctx context.Context, event, command string, timeout time.Duration, env map[string]string, payload any) error {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	// Don't wait forever on grandchildren still holding our pipes
+	cmd.WaitDelay = time.Second
+
+	cmd.Env = append(os.Environ(), "TINITUI_HOOK="+event)
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s hook timed out after %s", event, timeout)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > maxOutput {
+			msg = msg[:maxOutput] + "..."
+		}
+		if msg != "" {
+			return fmt.Errorf("%s hook failed: %w: %s", event, err, msg)
+		}
+		return fmt.Errorf("%s hook failed: %w", event, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPassesEnvAndPayload(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	cmd := `printf '%s ' "$TINITUI_HOOK" "$TINITUI_JOB_INPUT" > ` + out + ` && cat >> ` + out
+
+	err := Run(context.Background(), AfterCompress, cmd, 0,
+		map[string]string{"TINITUI_JOB_INPUT": "a.png"},
+		map[string]string{"input": "a.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(out)
+	want := `after_compress a.png {"input":"a.png"}`
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRunFailureAndTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	err := Run(context.Background(), OnFailure, "echo purge failed >&2; exit 3", 0, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "purge failed") {
+		t.Errorf("expected stderr in error, got %v", err)
+	}
+
+	err = Run(context.Background(), BeforeCompress, "sleep 5", 100*time.Millisecond, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout, got %v", err)
+	}
+}
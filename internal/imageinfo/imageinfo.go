@@ -0,0 +1,208 @@
+package imageinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format names reported in Info.
+const (
+	PNG  = "png"
+	JPEG = "jpeg"
+	WebP = "webp"
+	AVIF = "avif"
+)
+
+// sniffLen is how many leading bytes Sniff needs.
+const sniffLen = 16
+
+// Sniff returns the image format identified by the magic bytes at the start
+// of header, or "" if it isn't a supported image.
+func Sniff(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, pngSignature):
+		return PNG
+	case bytes.HasPrefix(header, []byte{0xff, 0xd8, 0xff}):
+		return JPEG
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return WebP
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		// ISO-BMFF: the major brand tells AVIF apart from HEIC, MP4 etc.
+		switch string(header[8:12]) {
+		case "avif", "avis":
+			return AVIF
+		}
+	}
+	return ""
+}
+
+// SniffFile reads the start of the file at path and sniffs its format.
+func SniffFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return Sniff(header[:n]), nil
+}
+
+// FormatForExt returns the format a file extension claims, or "".
+func FormatForExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".png":
+		return PNG
+	case ".jpg", ".jpeg":
+		return JPEG
+	case ".webp":
+		return WebP
+	case ".avif":
+		return AVIF
+	}
+	return ""
+}
+
+// Ext returns the canonical file extension for format.
+func Ext(format string) string {
+	switch format {
+	case JPEG:
+		return ".jpg"
+	case "":
+		return ""
+	}
+	return "." + format
+}
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	errTruncated = errors.New("truncated image header")
+)
+
+// Info is what can be learnt about an image from its headers alone.
+type Info struct {
+	Format   string
+	Width    int // Zero if the header could not be decoded
+	Height   int
+	Animated bool // APNG (acTL chunk) or animated WebP (ANIM chunk)
+}
+
+// Megapixels returns the pixel count in millions.
+func (i *Info) Megapixels() float64 {
+	return float64(i.Width) * float64(i.Height) / 1e6
+}
+
+// Inspect reads the headers of the image at path. The format is sniffed from
+// the content, falling back to the extension; animation is detected by walking
+// the container chunks and dimensions come from image.DecodeConfig.
+func Inspect(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, header)
+	info := &Info{Format: Sniff(header[:n])}
+	if info.Format == "" {
+		info.Format = FormatForExt(filepath.Ext(path))
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch info.Format {
+	case PNG:
+		info.Animated, err = pngAnimated(f)
+	case WebP:
+		info.Animated, err = webpAnimated(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if cfg, _, err := image.DecodeConfig(f); err == nil {
+		info.Width = cfg.Width
+		info.Height = cfg.Height
+	}
+	return info, nil
+}
+
+// pngAnimated reports whether the PNG has an acTL chunk, which APNG requires
+// to appear before the first IDAT.
+func pngAnimated(r io.ReadSeeker) (bool, error) {
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return false, errTruncated
+	}
+	if !bytes.Equal(sig, pngSignature) {
+		return false, errors.New("not a PNG file")
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return false, errTruncated
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		switch string(header[4:8]) {
+		case "acTL":
+			return true, nil
+		case "IDAT", "IEND":
+			return false, nil
+		}
+		// Skip chunk data and CRC
+		if _, err := r.Seek(int64(length)+4, io.SeekCurrent); err != nil {
+			return false, err
+		}
+	}
+}
+
+// webpAnimated reports whether the WebP container has an ANIM chunk.
+func webpAnimated(r io.ReadSeeker) (bool, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return false, errTruncated
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WEBP" {
+		return false, errors.New("not a WebP file")
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, errTruncated
+		}
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		switch string(header[0:4]) {
+		case "ANIM", "ANMF":
+			return true, nil
+		case "VP8 ", "VP8L":
+			// Simple format: a single still frame
+			return false, nil
+		}
+		// Chunks are padded to an even size
+		if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package imageinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func pngChunk(typ string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	b = append(b, typ...)
+	b = append(b, data...)
+	return append(b, 0, 0, 0, 0) // CRC is not checked
+}
+
+func webpChunk(fourcc string, data []byte) []byte {
+	b := append([]byte(fourcc), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func webpFile(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+func TestPNGAnimated(t *testing.T) {
+	still := append(append(append([]byte{}, pngSignature...), pngChunk("IHDR", make([]byte, 13))...), pngChunk("IDAT", []byte{1})...)
+	anim := append(append(append(append([]byte{}, pngSignature...), pngChunk("IHDR", make([]byte, 13))...), pngChunk("acTL", make([]byte, 8))...), pngChunk("IDAT", []byte{1})...)
+
+	if got, err := pngAnimated(bytes.NewReader(still)); err != nil || got {
+		t.Errorf("still PNG: got %v, %v", got, err)
+	}
+	if got, err := pngAnimated(bytes.NewReader(anim)); err != nil || !got {
+		t.Errorf("APNG: got %v, %v", got, err)
+	}
+	if _, err := pngAnimated(bytes.NewReader([]byte("GIF89a"))); err == nil {
+		t.Error("expected error for non-PNG data")
+	}
+}
+
+func TestWebPAnimated(t *testing.T) {
+	lossy := webpFile(webpChunk("VP8 ", make([]byte, 11)))
+	anim := webpFile(webpChunk("VP8X", make([]byte, 10)), webpChunk("ANIM", make([]byte, 6)), webpChunk("ANMF", make([]byte, 17)))
+
+	if got, err := webpAnimated(bytes.NewReader(lossy)); err != nil || got {
+		t.Errorf("still WebP: got %v, %v", got, err)
+	}
+	if got, err := webpAnimated(bytes.NewReader(anim)); err != nil || !got {
+		t.Errorf("animated WebP: got %v, %v", got, err)
+	}
+}
+
+func TestDecodeWebPConfig(t *testing.T) {
+	// VP8L: signature, then width-1 and height-1 in 14 bits each
+	bits := uint32(640-1) | uint32(480-1)<<14
+	lossless := webpFile(webpChunk("VP8L", append(append([]byte{0x2f}, binary.LittleEndian.AppendUint32(nil, bits)...), make([]byte, 5)...)))
+
+	vp8x := make([]byte, 10)
+	vp8x[4], vp8x[5] = 0x7f, 0x07 // 1920-1
+	vp8x[7], vp8x[8] = 0x37, 0x04 // 1080-1
+	extended := webpFile(webpChunk("VP8X", vp8x))
+
+	for name, data := range map[string][]byte{"VP8L": lossless, "VP8X": extended} {
+		cfg, err := decodeWebPConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := map[string][2]int{"VP8L": {640, 480}, "VP8X": {1920, 1080}}[name]
+		if cfg.Width != want[0] || cfg.Height != want[1] {
+			t.Errorf("%s: got %dx%d, want %dx%d", name, cfg.Width, cfg.Height, want[0], want[1])
+		}
+	}
+}
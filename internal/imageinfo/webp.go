@@ -0,0 +1,59 @@
+package imageinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+func init() {
+	// Only the header is understood; decoding pixels is left to the API.
+	image.RegisterFormat(WebP, "RIFF????WEBP", decodeWebP, decodeWebPConfig)
+}
+
+func decodeWebP(io.Reader) (image.Image, error) {
+	return nil, errors.New("webp: decoding pixels is not supported")
+}
+
+// decodeWebPConfig reads the canvas size from the first chunk of a WebP file.
+func decodeWebPConfig(r io.Reader) (image.Config, error) {
+	var header [20]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return image.Config{}, errTruncated
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return image.Config{}, errors.New("webp: not a WebP file")
+	}
+
+	var data [10]byte
+	if _, err := io.ReadFull(r, data[:]); err != nil {
+		return image.Config{}, errTruncated
+	}
+
+	cfg := image.Config{}
+	switch string(header[12:16]) {
+	case "VP8 ":
+		// 3 byte frame tag, 3 byte start code, then 14 bit width and height
+		if data[3] != 0x9d || data[4] != 0x01 || data[5] != 0x2a {
+			return cfg, errors.New("webp: bad VP8 start code")
+		}
+		cfg.Width = int(binary.LittleEndian.Uint16(data[6:8]) & 0x3fff)
+		cfg.Height = int(binary.LittleEndian.Uint16(data[8:10]) & 0x3fff)
+	case "VP8L":
+		// Signature byte, then width-1 and height-1 packed in 14 bits each
+		if data[0] != 0x2f {
+			return cfg, errors.New("webp: bad VP8L signature")
+		}
+		bits := binary.LittleEndian.Uint32(data[1:5])
+		cfg.Width = int(bits&0x3fff) + 1
+		cfg.Height = int((bits>>14)&0x3fff) + 1
+	case "VP8X":
+		// Flags, 3 reserved bytes, then 24 bit canvas width-1 and height-1
+		cfg.Width = int(uint32(data[4])|uint32(data[5])<<8|uint32(data[6])<<16) + 1
+		cfg.Height = int(uint32(data[7])|uint32(data[8])<<8|uint32(data[9])<<16) + 1
+	default:
+		return cfg, errors.New("webp: unknown chunk " + string(header[12:16]))
+	}
+	return cfg, nil
+}
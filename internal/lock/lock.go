@@ -0,0 +1,90 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned by TryAcquire when another process holds the lock.
+var ErrLocked = errors.New("locked by another process")
+
+// pollInterval is how often Acquire retries a held lock.
+const pollInterval = 100 * time.Millisecond
+
+// Lock is an advisory, exclusive lock on a file. It only excludes other
+// processes (and other Locks in this process) that use this package; it
+// does not stop plain reads or writes.
+type Lock struct {
+	f    *os.File
+	path string
+}
+
+// TryAcquire takes the lock on path without waiting, creating the lock file
+// and its directory if needed. It returns ErrLocked if the lock is held.
+func TryAcquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		// The holder may have removed the file between our open and lock,
+		// leaving us the lock on a file nobody else will find: start over
+		if current(f, path) {
+			return &Lock{f: f, path: path}, nil
+		}
+		unlockFile(f)
+		f.Close()
+	}
+}
+
+// current reports whether the open file f is still the one at path.
+func current(f *os.File, path string) bool {
+	held, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	named, err := os.Stat(path)
+	return err == nil && os.SameFile(held, named)
+}
+
+// Acquire waits until the lock on path is free or ctx is done.
+func Acquire(ctx context.Context, path string) (*Lock, error) {
+	for {
+		l, err := TryAcquire(path)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Release drops the lock, leaving the lock file in place.
+func (l *Lock) Release() error {
+	if err := unlockFile(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// ReleaseAndRemove drops the lock and removes the lock file, for locks on
+// paths that vary, such as one per output file, which would otherwise pile
+// up. Processes that opened the file meanwhile notice its removal and retry.
+func (l *Lock) ReleaseAndRemove() error {
+	removeLocked(l.path)
+	return l.Release()
+}
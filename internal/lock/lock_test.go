@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTryAcquireExcludes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "target.lock")
+
+	first, err := TryAcquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TryAcquire(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*pollInterval)
+	defer cancel()
+	if _, err := Acquire(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Acquire to time out, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(pollInterval)
+		first.Release()
+	}()
+	second, err := Acquire(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Release()
+}
+
+func TestReleaseAndRemove(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lock files are kept on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "target.lock")
+
+	first, err := TryAcquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(pollInterval)
+		first.ReleaseAndRemove()
+	}()
+	second, err := Acquire(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The waiter holds the lock on the file at path, not the removed one
+	if _, err := TryAcquire(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if err := second.ReleaseAndRemove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a snapshot of a job taken when its status changed.
+type Event struct {
+	Job  Job
+	Time time.Time
+}
+
+// Events returns a channel that receives every job status change in order.
+// Unlike Updates it never drops one, so callers can count finished jobs. It
+// must be called before files are added and is closed by Stop.
+func (p *Pipeline) Events() <-chan Event {
+	if p.events == nil {
+		p.events = newEventQueue()
+	}
+	return p.events.out
+}
+
+// eventQueue buffers events without bound between the workers pushing them
+// and a consumer that may lag behind.
+type eventQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  []Event
+	closed bool
+	out    chan Event
+}
+
+func newEventQueue() *eventQueue {
+	q := &eventQueue{out: make(chan Event)}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+func (q *eventQueue) push(e Event) {
+	q.mu.Lock()
+	q.items = append(q.items, e)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// run delivers queued events, then closes out once closed and drained.
+func (q *eventQueue) run() {
+	for {
+		q.mu.Lock()
+		for len(q.items) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.items) == 0 {
+			q.mu.Unlock()
+			close(q.out)
+			return
+		}
+		e := q.items[0]
+		q.items = q.items[1:]
+		q.mu.Unlock()
+		q.out <- e
+	}
+}
@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/hooks"
+)
+
+// jobPayload is the JSON document per-job hooks receive on stdin.
+type jobPayload struct {
+	Event          string  `json:"event"`
+	Input          string  `json:"input"`
+	Output         string  `json:"output"`
+	Status         string  `json:"status"`
+	OriginalSize   int64   `json:"original_size"`
+	CompressedSize int64   `json:"compressed_size"`
+	SavedBytes     int64   `json:"saved_bytes"`
+	SavedPercent   float64 `json:"saved_percent"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// RunSummary is the JSON document the on_complete hook receives on stdin.
+type RunSummary struct {
+	Event          string       `json:"event"`
+	Total          int          `json:"total"`
+	Done           int          `json:"done"`
+	Failed         int          `json:"failed"`
+	Skipped        int          `json:"skipped"`
+	OriginalSize   int64        `json:"original_size"`
+	CompressedSize int64        `json:"compressed_size"`
+	SavedBytes     int64        `json:"saved_bytes"`
+	Jobs           []jobPayload `json:"jobs"`
+}
+
+func (p *Pipeline) hookTimeout() time.Duration {
+	return time.Duration(p.config.Hooks.Timeout) * time.Second
+}
+
+// runJobHook runs command for job if it is configured.
+func (p *Pipeline) runJobHook(event, command string, job *Job) error {
+	if command == "" {
+		return nil
+	}
+	payload := newJobPayload(event, job)
+	env := map[string]string{
+		"TINITUI_JOB_INPUT":           payload.Input,
+		"TINITUI_JOB_OUTPUT":          payload.Output,
+		"TINITUI_JOB_STATUS":          payload.Status,
+		"TINITUI_JOB_ORIGINAL_SIZE":   strconv.FormatInt(payload.OriginalSize, 10),
+		"TINITUI_JOB_COMPRESSED_SIZE": strconv.FormatInt(payload.CompressedSize, 10),
+		"TINITUI_JOB_SAVED_BYTES":     strconv.FormatInt(payload.SavedBytes, 10),
+		"TINITUI_JOB_ERROR":           payload.Error,
+	}
+	return hooks.Run(p.ctx, event, command, p.hookTimeout(), env, payload)
+}
+
+func newJobPayload(event string, job *Job) jobPayload {
+	payload := jobPayload{
+		Event:          event,
+		Input:          job.FilePath,
+		Output:         job.OutputPath,
+		Status:         string(job.Status),
+		OriginalSize:   job.OriginalSize,
+		CompressedSize: job.CompressedSize,
+		SavedBytes:     job.SavedBytes,
+		SavedPercent:   job.SavedPercent,
+	}
+	if job.Error != nil {
+		payload.Error = job.Error.Error()
+	}
+	return payload
+}
+
+// Summary totals the current jobs.
+func (p *Pipeline) Summary() RunSummary {
+	sum := RunSummary{Event: hooks.OnComplete}
+	for _, job := range p.Jobs() {
+		sum.Total++
+		switch job.Status {
+		case StatusDone:
+			sum.Done++
+			sum.OriginalSize += job.OriginalSize
+			sum.CompressedSize += job.CompressedSize
+			sum.SavedBytes += job.SavedBytes
+		case StatusFailed:
+			sum.Failed++
+		case StatusSkipped:
+			sum.Skipped++
+		}
+		sum.Jobs = append(sum.Jobs, newJobPayload(hooks.OnComplete, job))
+	}
+	return sum
+}
+
+// RunComplete flushes the recorded history and runs the on_complete hook
+// with the summary of the current jobs. Callers invoke it once every queued
+// job has finished.
+func (p *Pipeline) RunComplete() error {
+	var histErr error
+	if p.history != nil {
+		if err := p.history.Flush(); err != nil {
+			histErr = fmt.Errorf("saving history: %w", err)
+		}
+	}
+	return errors.Join(histErr, p.runCompleteHook())
+}
+
+func (p *Pipeline) runCompleteHook() error {
+	command := p.config.Hooks.OnComplete
+	if command == "" {
+		return nil
+	}
+	sum := p.Summary()
+	env := map[string]string{
+		"TINITUI_RUN_TOTAL":       strconv.Itoa(sum.Total),
+		"TINITUI_RUN_DONE":        strconv.Itoa(sum.Done),
+		"TINITUI_RUN_FAILED":      strconv.Itoa(sum.Failed),
+		"TINITUI_RUN_SKIPPED":     strconv.Itoa(sum.Skipped),
+		"TINITUI_RUN_SAVED_BYTES": strconv.FormatInt(sum.SavedBytes, 10),
+	}
+	// Stop has already cancelled the pipeline context by the time callers
+	// get here, so only the hook timeout bounds on_complete
+	return hooks.Run(context.Background(), hooks.OnComplete, command, p.hookTimeout(), env, sum)
+}
@@ -2,13 +2,22 @@ package pipeline
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gmsakibursabbir/tinitui/internal/config"
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+	"github.com/gmsakibursabbir/tinitui/internal/hooks"
+	"github.com/gmsakibursabbir/tinitui/internal/imageinfo"
+	"github.com/gmsakibursabbir/tinitui/internal/lock"
 	"github.com/gmsakibursabbir/tinitui/internal/tinify"
 )
 
@@ -20,17 +29,36 @@ const (
 	StatusDone       JobStatus = "done"
 	StatusFailed     JobStatus = "failed"
 	StatusCancelled  JobStatus = "cancelled"
+	StatusSkipped    JobStatus = "skipped"
 )
 
+// IsTerminal reports whether a job in this status will not be processed further.
+func (s JobStatus) IsTerminal() bool {
+	return s == StatusDone || s == StatusFailed || s == StatusSkipped
+}
+
 type Job struct {
 	ID          string // Path as ID?
 	FilePath    string
+	OutputPath  string
+	SkipReason  string
+	Format      string
+	Width       int
+	Height      int
+	Animated    bool
 	OriginalSize int64
 	CompressedSize int64
 	Status      JobStatus
 	Error       error
 	SavedBytes  int64
 	SavedPercent float64
+	Started     time.Time     // When processing began
+	Duration    time.Duration // Until the job finished
+	InputHash   string        // SHA-256 of the uploaded file
+	OutputHash  string        // SHA-256 of the compressed file
+	Retries     int           // Requests to the API that had to be repeated
+	HookError   error         // From the after_compress hook; the job still succeeded
+	Warning     string        // The content disagrees with the extension
 }
 
 type Pipeline struct {
@@ -50,6 +78,14 @@ type Pipeline struct {
 	wg         sync.WaitGroup
 	
 	updates    chan *Job // For TUI to listen
+
+	events     *eventQueue // Lossless status changes, see Events
+
+	lockDir    string // Per-target locks shared with other tinitui processes
+
+	history    *history.Manager // Where job outcomes are recorded, if set
+	session    string
+	source     string
 }
 
 func New(cfg *config.Config, apiKey string) *Pipeline {
@@ -62,8 +98,12 @@ func New(cfg *config.Config, apiKey string) *Pipeline {
 		ctx:         ctx,
 		cancel:      cancel,
 		updates:     make(chan *Job, 100),
+		session:     newSessionID(),
 	}
 	p.pauseCond = sync.NewCond(&p.pauseMutex)
+	if stateDir, err := history.StateDir(); err == nil {
+		p.lockDir = filepath.Join(stateDir, "locks")
+	}
 	return p
 }
 
@@ -88,17 +128,45 @@ func (p *Pipeline) Stop() {
 	p.cancel()
 	p.wg.Wait()
 	close(p.updates)
+	if p.events != nil {
+		p.events.close()
+	}
 }
 
-func (p *Pipeline) AddFiles(paths []string) {
+// AddFiles queues paths and returns how many jobs were added; paths already
+// queued and not finished are ignored.
+func (p *Pipeline) AddFiles(paths []string) int {
+	added, failed := p.queueFiles(paths)
+	// The on_failure hook may be slow, so it runs without holding the job
+	// list other goroutines need
+	for _, job := range failed {
+		p.fail(job, job.Error)
+	}
+	return added
+}
+
+// queueFiles adds jobs for paths under the job lock. Jobs failing preflight
+// are marked failed and returned for AddFiles to finish.
+func (p *Pipeline) queueFiles(paths []string) (added int, failed []*Job) {
 	p.jobMutex.Lock()
 	defer p.jobMutex.Unlock()
+	if p.ctx.Err() != nil {
+		return 0, nil // Aborted or stopped
+	}
+
+	// Outputs already claimed by queued jobs, so two inputs never race for one file
+	claimed := make(map[string]string)
+	for _, j := range p.jobs {
+		if !j.Status.IsTerminal() {
+			claimed[j.OutputPath] = j.FilePath
+		}
+	}
 
 	for _, path := range paths {
 		// Check duplicates?
 		exists := false
 		for _, j := range p.jobs {
-			if j.FilePath == path && j.Status != StatusDone && j.Status != StatusFailed {
+			if j.FilePath == path && !j.Status.IsTerminal() {
 				exists = true
 				break
 			}
@@ -106,21 +174,32 @@ func (p *Pipeline) AddFiles(paths []string) {
 		if exists {
 			continue
 		}
-
-		info, err := os.Stat(path)
-		size := int64(0)
-		if err == nil {
-			size = info.Size()
-		}
+		added++
 
 		job := &Job{
-			ID:           path,
-			FilePath:     path,
-			OriginalSize: size,
-			Status:       StatusPending,
+			ID:         path,
+			FilePath:   path,
+			OutputPath: OutputPath(p.config, path, ""),
+			Status:     StatusPending,
 		}
 		p.jobs = append(p.jobs, job)
-		
+
+		reason, err := preflight(p.config, job, claimed)
+		if err != nil {
+			job.Error = err
+			job.Status = StatusFailed
+			failed = append(failed, job)
+			continue
+		}
+		if reason != "" {
+			p.skip(job, reason)
+			continue
+		}
+		claimed[job.OutputPath] = job.FilePath
+
+		// Notify update before a worker can pick the job up
+		p.broadcast(job)
+
 		// Send to queue
 		select {
 		case p.queue <- job:
@@ -132,9 +211,22 @@ func (p *Pipeline) AddFiles(paths []string) {
 				p.queue <- j
 			}(job)
 		}
-		
-		// Notify update
-		p.broadcast(job)
+	}
+	return added, failed
+}
+
+// Abort cancels every job not finished yet: queued jobs are marked
+// cancelled and uploads in progress are interrupted. Stop must still be
+// called to wait for the workers.
+func (p *Pipeline) Abort() {
+	p.jobMutex.Lock()
+	defer p.jobMutex.Unlock()
+	p.cancel()
+	for _, job := range p.jobs {
+		if job.Status == StatusPending {
+			job.Status = StatusCancelled
+			p.broadcast(job)
+		}
 	}
 }
 
@@ -185,15 +277,32 @@ func (p *Pipeline) worker(id int) {
 }
 
 func (p *Pipeline) process(job *Job) {
+	job.Started = time.Now()
 	job.Status = StatusProcessing
 	p.broadcast(job)
 
+	targetLock, err := p.lockTarget(job)
+	if errors.Is(err, lock.ErrLocked) {
+		p.skip(job, "in progress in another tinitui process")
+		return
+	}
+	if err != nil {
+		p.fail(job, err)
+		return
+	}
+	if targetLock != nil {
+		defer targetLock.ReleaseAndRemove()
+	}
+
+	if err := p.runJobHook(hooks.BeforeCompress, p.config.Hooks.BeforeCompress, job); err != nil {
+		p.fail(job, err)
+		return
+	}
+
 	// Open file
 	f, err := os.Open(job.FilePath)
 	if err != nil {
-		job.Error = err
-		job.Status = StatusFailed
-		p.broadcast(job)
+		p.fail(job, err)
 		return
 	}
 	defer f.Close()
@@ -205,9 +314,7 @@ func (p *Pipeline) process(job *Job) {
 	// Create temp file
 	tmpFile, err := os.CreateTemp("", "tiny-*.tmp")
 	if err != nil {
-		job.Error = err
-		job.Status = StatusFailed
-		p.broadcast(job)
+		p.fail(job, err)
 		return
 	}
 	tmpName := tmpFile.Name()
@@ -221,92 +328,39 @@ func (p *Pipeline) process(job *Job) {
 		}
 	}()
 
-	r, compressedSize, _, err := p.client.Compress(p.ctx, f, filepath.Base(job.FilePath))
+	inHash := sha256.New()
+	ctx := tinify.WithRetryCounter(p.ctx, &job.Retries)
+	r, compressedSize, _, err := p.client.Compress(ctx, io.TeeReader(f, inHash), filepath.Base(job.FilePath))
 	if err != nil {
 		tmpFile.Close()
-		job.Error = err
-		job.Status = StatusFailed
-		p.broadcast(job)
+		p.fail(job, err)
 		return
 	}
 	defer r.Close()
+	job.InputHash = hex.EncodeToString(inHash.Sum(nil))
 
 	// content is in r. copy to tmpFile
-	if _, err := io.Copy(tmpFile, r); err != nil {
+	outHash := sha256.New()
+	if _, err := io.Copy(tmpFile, io.TeeReader(r, outHash)); err != nil {
 		tmpFile.Close()
-		job.Error = err
-		job.Status = StatusFailed
-		p.broadcast(job)
+		p.fail(job, err)
 		return
 	}
 	tmpFile.Close()
+	job.OutputHash = hex.EncodeToString(outHash.Sum(nil))
 
-	// Rename / Move
-	// "Replace original (atomic safe replace)" OR "Output directory"
-	// Suffix defaults to .tiny in config?
-	
-	// CLI/TUI logic might vary? Pipeline should know dest.
-	// For "replace", we use os.Rename (atomic on POSIX mostly)
-	
-	var finalPath string
-	if p.config.OutputMode == "replace" {
-		// Replace original
-		// If suffix is set, we prefer to append invalidating replace?
-		// "Replace original (atomic safe replace)" usually means overwriting input file.
-		// "Filename suffix (.tiny default)" implies we DON'T overwrite by default?
-		
-		// Wait, user Requirement 6:
-		// "Output Modes: Replace original ... OR Output directory"
-		// "Filename suffix (.tiny default)"
-		
-		// If replace original mode is ON, do we also use suffix?
-		// Typically "Replace" implies overwrite. "Suffix" implies new file.
-		// Let's assume if config.Suffix is empty, we overwrite. If set, we append to name.
-		// But defaulting to .tiny suggests we don't overwrite by default.
-		
-		// For now implementing: if OutputDir is set, go there.
-		// Else, use same dir.
-		// If Suffix is set, append it.
-		
-		if p.config.OutputDir != "" {
-			// output dir
-			base := filepath.Base(job.FilePath)
-			if p.config.Suffix != "" {
-				ext := filepath.Ext(base)
-				name := strings.TrimSuffix(base, ext)
-				base = name + p.config.Suffix + ext // foo.tiny.png
-			}
-			finalPath = filepath.Join(p.config.OutputDir, base)
-		} else {
-			// same dir
-			if p.config.Suffix != "" {
-				ext := filepath.Ext(job.FilePath)
-				name := strings.TrimSuffix(job.FilePath, ext)
-				finalPath = name + p.config.Suffix + ext
-			} else {
-				// Overwrite!
-				finalPath = job.FilePath
-			}
-		}
-	} else {
-		// OutputDir mode (redundant if logic above covers it)
-		// Let's stick to logic above.
-	}
+	finalPath := job.OutputPath
 
 	// Ensure dir exists if output dir
 	if err := os.MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
-		job.Error = err
-		job.Status = StatusFailed
-		p.broadcast(job)
+		p.fail(job, err)
 		return
 	}
 
 	if err := os.Rename(tmpName, finalPath); err != nil {
 		// copy fallback for cross-device
 		if err := copyFile(tmpName, finalPath); err != nil {
-			job.Error = err
-			job.Status = StatusFailed
-			p.broadcast(job)
+			p.fail(job, err)
 			return
 		}
 	}
@@ -318,11 +372,67 @@ func (p *Pipeline) process(job *Job) {
 	if job.OriginalSize > 0 {
 		job.SavedPercent = float64(job.SavedBytes) / float64(job.OriginalSize) * 100
 	}
+
+	// The output is already written, so a failing hook doesn't undo the
+	// compression; it is reported alongside so scripts relying on it (CDN
+	// purge, git add) don't go unnoticed.
+	if err := p.runJobHook(hooks.AfterCompress, p.config.Hooks.AfterCompress, job); err != nil {
+		job.HookError = err
+	}
 	job.Status = StatusDone
+	p.finish(job)
+	p.broadcast(job)
+}
+
+// lockTarget takes the cross-process lock on the job's output path. Depending
+// on the on_locked setting it waits for the lock or returns lock.ErrLocked.
+func (p *Pipeline) lockTarget(job *Job) (*lock.Lock, error) {
+	if p.lockDir == "" {
+		return nil, nil
+	}
+	target, err := filepath.Abs(job.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(target))
+	path := filepath.Join(p.lockDir, hex.EncodeToString(sum[:16])+".lock")
+
+	if p.config.OnLocked == config.OnLockedWait {
+		return lock.Acquire(p.ctx, path)
+	}
+	return lock.TryAcquire(path)
+}
+
+func (p *Pipeline) skip(job *Job, reason string) {
+	job.SkipReason = reason
+	job.Status = StatusSkipped
+	p.finish(job)
 	p.broadcast(job)
 }
 
+// fail marks job as failed with err and runs the on_failure hook.
+func (p *Pipeline) fail(job *Job, err error) {
+	if errors.Is(err, context.Canceled) && p.ctx.Err() != nil {
+		// Interrupted by Abort or Stop, which is no fault of the job
+		job.Status = StatusCancelled
+		p.broadcast(job)
+		return
+	}
+	job.Error = err
+	job.Status = StatusFailed
+	if hookErr := p.runJobHook(hooks.OnFailure, p.config.Hooks.OnFailure, job); hookErr != nil {
+		job.Error = fmt.Errorf("%w (%v)", err, hookErr)
+	}
+	p.finish(job)
+	p.broadcast(job)
+}
+
+// broadcast notifies listeners of a job's new status. Updates may drop
+// notifications when the listener lags; Events never does.
 func (p *Pipeline) broadcast(job *Job) {
+	if p.events != nil {
+		p.events.push(Event{Job: *job, Time: time.Now()})
+	}
 	select {
 	case p.updates <- job:
 	default:
@@ -333,6 +443,88 @@ func (p *Pipeline) Updates() <-chan *Job {
 	return p.updates
 }
 
+// OutputPath resolves where the compressed copy of input is written.
+// With an output directory the file keeps its base name there; otherwise it
+// stays next to the original. A non-empty suffix is inserted before the
+// extension (foo.png -> foo.tiny.png); without one the original is replaced.
+// With fix_extensions the extension is replaced by the one matching format,
+// the content's format if known (upload -> upload.jpg, logo.jpg holding a
+// PNG -> logo.png); preflight sets it once it has read the headers.
+func OutputPath(cfg *config.Config, input, format string) string {
+	base := filepath.Base(input)
+	if cfg.FixExtensions {
+		ext := filepath.Ext(base)
+		if format != "" && imageinfo.FormatForExt(ext) != format {
+			if imageinfo.FormatForExt(ext) != "" {
+				base = strings.TrimSuffix(base, ext)
+			}
+			base += imageinfo.Ext(format)
+		}
+	}
+	if cfg.Suffix != "" {
+		ext := filepath.Ext(base)
+		base = strings.TrimSuffix(base, ext) + cfg.Suffix + ext
+	}
+	if cfg.OutputDir != "" {
+		return filepath.Join(cfg.OutputDir, base)
+	}
+	return filepath.Join(filepath.Dir(input), base)
+}
+
+// preflight fills in the job's size and image details and applies the
+// per-file policies. It returns a skip reason, or an error if the policy says
+// the job must fail. claimed maps output paths already taken to the input that
+// produces them.
+func preflight(cfg *config.Config, job *Job, claimed map[string]string) (string, error) {
+	info, err := os.Stat(job.FilePath)
+	if err != nil {
+		return "unreadable: " + err.Error(), nil
+	}
+	job.OriginalSize = info.Size()
+
+	// Size limits are checked before anything is uploaded: the API only
+	// rejects oversized files after the full upload, and tiny files aren't
+	// worth a compression from the quota.
+	if cfg.MinBytes > 0 && job.OriginalSize < cfg.MinBytes {
+		return fmt.Sprintf("smaller than min_bytes (%d < %d bytes)", job.OriginalSize, cfg.MinBytes), nil
+	}
+	if cfg.MaxBytes > 0 && job.OriginalSize > cfg.MaxBytes {
+		return fmt.Sprintf("larger than max_bytes (%d > %d bytes)", job.OriginalSize, cfg.MaxBytes), nil
+	}
+
+	if meta, err := imageinfo.Inspect(job.FilePath); err == nil {
+		job.Format = meta.Format
+		job.Width = meta.Width
+		job.Height = meta.Height
+		job.Animated = meta.Animated
+		// The scanner takes image extensions at their word, so a renamed
+		// image is only caught here, where the content is read anyway
+		ext := filepath.Ext(job.FilePath)
+		if extFormat := imageinfo.FormatForExt(ext); extFormat != "" && meta.Format != "" && meta.Format != extFormat {
+			job.Warning = fmt.Sprintf("extension %s but content is %s", ext, strings.ToUpper(meta.Format))
+		}
+		if cfg.FixExtensions {
+			job.OutputPath = OutputPath(cfg, job.FilePath, meta.Format)
+		}
+		if cfg.MaxMegapixels > 0 && meta.Megapixels() > cfg.MaxMegapixels {
+			return fmt.Sprintf("%dx%d exceeds max_megapixels (%.1f > %.1f MP)",
+				meta.Width, meta.Height, meta.Megapixels(), cfg.MaxMegapixels), nil
+		}
+	}
+	if other, ok := claimed[job.OutputPath]; ok {
+		return "output collides with " + other, nil
+	}
+	if job.Animated {
+		switch cfg.Animated {
+		case config.AnimatedSkip:
+			return "animated image", nil
+		case config.AnimatedError:
+			return "", errors.New("animated image rejected by the animated policy")
+		}
+	}
+	return "", nil
+}
+
 func copyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil { return err }
@@ -372,7 +564,7 @@ func (p *Pipeline) ClearCompleted() {
 	defer p.jobMutex.Unlock()
 	n := 0
 	for _, x := range p.jobs {
-		if x.Status != StatusDone && x.Status != StatusFailed {
+		if !x.Status.IsTerminal() {
 			p.jobs[n] = x
 			n++
 		}
@@ -0,0 +1,275 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/config"
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+)
+
+func TestRecordsSkippedJobs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	h, err := history.Open(filepath.Join(t.TempDir(), history.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(config.DefaultConfig(), "secret-key-1234")
+	p.SetHistory(h, history.SourceCLI)
+
+	p.AddFiles([]string{filepath.Join(t.TempDir(), "missing.png")})
+	if err := p.RunComplete(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := h.All()
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	r := records[0]
+	if r.Status != history.StatusSkipped || r.Session != p.Session() || r.Source != history.SourceCLI || r.KeyAlias != "...1234" || r.Error == "" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
+
+func TestEventsAreLossless(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p := New(config.DefaultConfig(), "key")
+	events := p.Events()
+	p.Start()
+
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 500; i++ {
+		paths = append(paths, filepath.Join(dir, fmt.Sprintf("missing-%d.png", i)))
+	}
+	// Nobody reads while the files are added, which Updates would drop
+	if n := p.AddFiles(paths); n != len(paths) {
+		t.Fatalf("AddFiles added %d, want %d", n, len(paths))
+	}
+	p.Stop()
+
+	finished := 0
+	for e := range events {
+		if e.Job.Status.IsTerminal() {
+			finished++
+		}
+	}
+	if finished != len(paths) {
+		t.Errorf("got %d finished events, want %d", finished, len(paths))
+	}
+}
+
+func TestAbortCancelsQueuedJobs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p := New(config.DefaultConfig(), "key")
+	events := p.Events()
+
+	// A 1x1 PNG; without workers the jobs stay queued
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00\x90wS\xde")
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%d.png", i))
+		if err := os.WriteFile(path, png, 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	if n := p.AddFiles(paths); n != len(paths) {
+		t.Fatalf("AddFiles added %d, want %d", n, len(paths))
+	}
+	p.Abort()
+	if n := p.AddFiles([]string{filepath.Join(dir, "late.png")}); n != 0 {
+		t.Errorf("AddFiles after Abort added %d jobs", n)
+	}
+	p.Stop()
+
+	cancelled := 0
+	for e := range events {
+		if e.Job.Status == StatusCancelled {
+			cancelled++
+		}
+	}
+	if cancelled != len(paths) {
+		t.Errorf("got %d cancelled events, want %d", cancelled, len(paths))
+	}
+}
+
+func TestFailureHookRunsOutsideJobLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Animated = config.AnimatedError
+	cfg.Hooks.OnFailure = "sleep 2"
+	p := New(cfg, "key")
+
+	chunk := func(typ string, data []byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+		b = append(append(b, typ...), data...)
+		return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(append([]byte(typ), data...)))
+	}
+	apng := []byte("\x89PNG\r\n\x1a\n")
+	apng = append(apng, chunk("IHDR", make([]byte, 13))...)
+	apng = append(apng, chunk("acTL", make([]byte, 8))...)
+	path := filepath.Join(t.TempDir(), "anim.png")
+	if err := os.WriteFile(path, apng, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.AddFiles([]string{path})
+		close(done)
+	}()
+	time.Sleep(300 * time.Millisecond)
+	jobs := p.Jobs()
+	select {
+	case <-done:
+		t.Fatal("Jobs waited for the on_failure hook")
+	default:
+	}
+	<-done
+	if len(jobs) != 1 || jobs[0].Status != StatusFailed {
+		t.Errorf("unexpected jobs %+v", jobs)
+	}
+}
+
+func TestCompleteHookRunsAfterStop(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	marker := filepath.Join(t.TempDir(), "complete")
+	cfg := config.DefaultConfig()
+	cfg.Hooks.OnComplete = "touch '" + marker + "'"
+	p := New(cfg, "key")
+
+	p.Start()
+	p.AddFiles([]string{filepath.Join(t.TempDir(), "missing.png")})
+	p.Stop()
+	if err := p.RunComplete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("on_complete hook did not run: %v", err)
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{"a/logo.png": 1000, "b/logo.png": 1000, "photo.jpg": 2000, "tiny.png": 10}
+	for name, size := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := func(name string) string { return filepath.Join(dir, name) }
+	ratios := map[string]float64{"png": 0.5, "*": 0.25}
+
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		inputs    []string
+		ratios    map[string]float64
+		skips     []string // Prefix of each entry's skip reason, "" when compressed
+		cost      int
+		estimated int
+		saved     int64
+	}{
+		{
+			name:      "ratios by format",
+			inputs:    []string{in("a/logo.png"), in("photo.jpg")},
+			ratios:    ratios,
+			skips:     []string{"", ""},
+			cost:      2,
+			estimated: 2,
+			saved:     500 + 1500,
+		},
+		{
+			name:   "no history",
+			inputs: []string{in("a/logo.png"), in("photo.jpg")},
+			skips:  []string{"", ""},
+			cost:   2,
+		},
+		{
+			name:      "skipped files",
+			configure: func(cfg *config.Config) { cfg.MinBytes = 100 },
+			inputs:    []string{in("tiny.png"), in("missing.png"), in("photo.jpg")},
+			ratios:    ratios,
+			skips:     []string{"smaller than min_bytes", "unreadable", ""},
+			cost:      1,
+			estimated: 1,
+			saved:     1500,
+		},
+		{
+			name:      "same output",
+			configure: func(cfg *config.Config) { cfg.OutputDir = filepath.Join(dir, "out") },
+			inputs:    []string{in("a/logo.png"), in("b/logo.png")},
+			ratios:    ratios,
+			skips:     []string{"", "output collides with " + in("a/logo.png")},
+			cost:      1,
+			estimated: 1,
+			saved:     500,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			plan := BuildPlan(cfg, tt.inputs, tt.ratios)
+			if len(plan.Entries) != len(tt.inputs) {
+				t.Fatalf("got %d entries, want %d", len(plan.Entries), len(tt.inputs))
+			}
+			for i, e := range plan.Entries {
+				if want := tt.skips[i]; (want == "") != (e.SkipReason == "") || !strings.HasPrefix(e.SkipReason, want) {
+					t.Errorf("%s skipped for %q, want %q", filepath.Base(e.Input), e.SkipReason, want)
+				}
+			}
+			if plan.QuotaCost != tt.cost || len(plan.Skipped()) != len(tt.inputs)-tt.cost || plan.Estimated != tt.estimated {
+				t.Errorf("cost %d, %d skipped, %d estimated", plan.QuotaCost, len(plan.Skipped()), plan.Estimated)
+			}
+			if saved, _ := plan.EstimatedSavings(); saved != tt.saved {
+				t.Errorf("estimated savings %d, want %d", saved, tt.saved)
+			}
+		})
+	}
+}
+
+func TestPlanFixesExtensionsFromContent(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"logo.jpg", "upload", "real.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.FixExtensions = true
+	cfg.Suffix = ".tiny"
+	plan := BuildPlan(cfg, []string{
+		filepath.Join(dir, "logo.jpg"),
+		filepath.Join(dir, "upload"),
+		filepath.Join(dir, "real.png"),
+	}, nil)
+	for i, want := range []string{"logo.tiny.png", "upload.tiny.png", "real.tiny.png"} {
+		if got := filepath.Base(plan.Entries[i].Output); got != want {
+			t.Errorf("output of %s = %s, want %s", filepath.Base(plan.Entries[i].Input), got, want)
+		}
+	}
+}
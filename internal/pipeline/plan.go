@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"os"
+
+	"github.com/gmsakibursabbir/tinitui/internal/config"
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+)
+
+// PlanEntry describes what a run would do with a single input file.
+type PlanEntry struct {
+	Input         string
+	Output        string
+	Format        string
+	Size          int64
+	EstimatedSize int64 // -1 when no historical ratio is available
+	Overwrites    bool  // Output exists and is not the input itself
+	Animated      bool
+	SkipReason    string
+	Warning       string // The content disagrees with the extension
+}
+
+// Plan is the dry-run result for a set of inputs. It resolves paths and
+// collisions exactly like AddFiles does, without touching the API.
+type Plan struct {
+	Entries []*PlanEntry
+
+	QuotaCost     int   // Compressions counted against the monthly quota
+	TotalSize     int64 // Size of the files that would be uploaded
+	EstimatedBase int64 // Size of the entries that have an estimate
+	EstimatedSize int64 // Estimated size of those entries after compression
+	Estimated     int   // How many entries have an estimate
+}
+
+// EstimatedSavings returns the estimated bytes saved and the matching percentage.
+func (p *Plan) EstimatedSavings() (int64, float64) {
+	saved := p.EstimatedBase - p.EstimatedSize
+	if p.EstimatedBase == 0 {
+		return saved, 0
+	}
+	return saved, float64(saved) / float64(p.EstimatedBase) * 100
+}
+
+// BuildPlan computes the input -> output mapping for paths under cfg.
+// ratios are after/before ratios as returned by history.Manager.CompressionRatios
+// and may be nil.
+func BuildPlan(cfg *config.Config, paths []string, ratios map[string]float64) *Plan {
+	plan := &Plan{}
+	claimed := make(map[string]string)
+
+	for _, path := range paths {
+		entry := &PlanEntry{
+			Input:         path,
+			Output:        OutputPath(cfg, path, ""),
+			Format:        history.FormatOf(path),
+			EstimatedSize: -1,
+		}
+		plan.Entries = append(plan.Entries, entry)
+
+		job := &Job{FilePath: path, OutputPath: entry.Output}
+		reason, err := preflight(cfg, job, claimed)
+		entry.Output = job.OutputPath
+		entry.Size = job.OriginalSize
+		entry.Animated = job.Animated
+		entry.Warning = job.Warning
+		if err != nil {
+			entry.SkipReason = "would fail: " + err.Error()
+			continue
+		}
+		if reason != "" {
+			entry.SkipReason = reason
+			continue
+		}
+		claimed[entry.Output] = entry.Input
+
+		if entry.Output != entry.Input {
+			if _, err := os.Stat(entry.Output); err == nil {
+				entry.Overwrites = true
+			}
+		}
+
+		plan.QuotaCost++
+		plan.TotalSize += entry.Size
+
+		ratio, ok := ratios[entry.Format]
+		if !ok {
+			ratio, ok = ratios["*"]
+		}
+		if ok {
+			entry.EstimatedSize = int64(float64(entry.Size) * ratio)
+			plan.EstimatedBase += entry.Size
+			plan.EstimatedSize += entry.EstimatedSize
+			plan.Estimated++
+		}
+	}
+	return plan
+}
+
+// Skipped returns the entries that would not be compressed.
+func (p *Plan) Skipped() []*PlanEntry {
+	var res []*PlanEntry
+	for _, e := range p.Entries {
+		if e.SkipReason != "" {
+			res = append(res, e)
+		}
+	}
+	return res
+}
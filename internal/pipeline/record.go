@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+)
+
+// newSessionID returns an ID for one run, sortable by start time.
+func newSessionID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%s", time.Now().Format("20060102T150405"), hex.EncodeToString(b))
+}
+
+// keyAlias identifies an API key in history without storing it.
+func keyAlias(key string) string {
+	if len(key) <= 4 {
+		return ""
+	}
+	return "..." + key[len(key)-4:]
+}
+
+// SetHistory makes the pipeline record every job outcome in h, tagged with
+// the session ID and source ("cli" or "tui").
+func (p *Pipeline) SetHistory(h *history.Manager, source string) {
+	p.history = h
+	p.source = source
+}
+
+// Session returns the ID recorded with this pipeline's history records.
+func (p *Pipeline) Session() string {
+	return p.session
+}
+
+// finish stamps the duration of a job that reached a terminal status and
+// records it in history.
+func (p *Pipeline) finish(job *Job) {
+	if !job.Started.IsZero() {
+		job.Duration = time.Since(job.Started)
+	}
+	if p.history == nil {
+		return
+	}
+	r := &history.Record{
+		Timestamp:   time.Now(),
+		Session:     p.session,
+		Source:      p.source,
+		File:        job.FilePath,
+		Output:      job.OutputPath,
+		Format:      job.Format,
+		Width:       job.Width,
+		Height:      job.Height,
+		BeforeSize:  job.OriginalSize,
+		InputSHA256: job.InputHash,
+		KeyAlias:    keyAlias(p.client.APIKey),
+		DurationMS:  job.Duration.Milliseconds(),
+		Retries:     job.Retries,
+	}
+	switch job.Status {
+	case StatusDone:
+		r.Status = history.StatusSuccess
+		r.AfterSize = job.CompressedSize
+		r.SavedBytes = job.SavedBytes
+		r.SavedPercent = job.SavedPercent
+		r.OutputSHA256 = job.OutputHash
+	case StatusSkipped:
+		r.Status = history.StatusSkipped
+		r.Error = job.SkipReason
+	default:
+		r.Status = history.StatusFailed
+		if job.Error != nil {
+			r.Error = job.Error.Error()
+		}
+	}
+	p.history.Add(r)
+}
@@ -0,0 +1,195 @@
+// Package report renders compression history as a self-contained HTML page.
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+)
+
+//go:embed report.html.tmpl
+var pageTemplate string
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+	"date":  func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+	"dict": func(kv ...any) map[string]any {
+		m := make(map[string]any, len(kv)/2)
+		for i := 0; i+1 < len(kv); i += 2 {
+			m[kv[i].(string)] = kv[i+1]
+		}
+		return m
+	},
+}).Parse(pageTemplate))
+
+// How many rows the tables show.
+const (
+	maxGroups   = 20
+	maxWins     = 10
+	maxFailures = 50
+)
+
+// Chart dimensions in SVG user units.
+const (
+	chartWidth  = 800
+	chartHeight = 240
+	chartMargin = 40
+)
+
+// Report is what the page shows.
+type Report struct {
+	Title     string
+	Generated time.Time
+	From, To  time.Time
+	Totals    history.Group
+	Chart     Chart
+	Dirs      []history.Group
+	Formats   []history.Group
+	Wins      []*history.Record
+	Failures  []*history.Record
+}
+
+// Chart is a bar chart of bytes saved per day or month.
+type Chart struct {
+	Width, Height int
+	Left          int    // x of the axis
+	Top, Base     int    // y of the highest bar and of the axis
+	Period        string // "day" or "month"
+	Bars          []Bar
+	Max           int64
+}
+
+// Bar is one period of the chart, laid out in SVG coordinates.
+type Bar struct {
+	X, Y, W, H float64
+	Label      string
+	Saved      int64
+	ShowLabel  bool
+}
+
+// Build summarises records, which may be in any order.
+func Build(title string, records []*history.Record) (*Report, error) {
+	r := &Report{
+		Title:     title,
+		Generated: time.Now(),
+		Totals:    history.Total(records),
+	}
+	if len(records) == 0 {
+		return r, nil
+	}
+	r.From, r.To = records[0].Timestamp, records[0].Timestamp
+	var successes, failures []*history.Record
+	for _, rec := range records {
+		if rec.Timestamp.Before(r.From) {
+			r.From = rec.Timestamp
+		}
+		if rec.Timestamp.After(r.To) {
+			r.To = rec.Timestamp
+		}
+		switch rec.Status {
+		case history.StatusSuccess:
+			successes = append(successes, rec)
+		case history.StatusFailed:
+			failures = append(failures, rec)
+		}
+	}
+
+	var err error
+	if r.Dirs, err = topGroups(records, "dir"); err != nil {
+		return nil, err
+	}
+	if r.Formats, err = topGroups(records, "format"); err != nil {
+		return nil, err
+	}
+	if r.Chart, err = buildChart(successes, r.From, r.To); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(successes, func(i, j int) bool { return successes[i].SavedBytes > successes[j].SavedBytes })
+	r.Wins = successes[:min(len(successes), maxWins)]
+	// Most recent failures first
+	sort.SliceStable(failures, func(i, j int) bool { return failures[i].Timestamp.After(failures[j].Timestamp) })
+	r.Failures = failures[:min(len(failures), maxFailures)]
+	return r, nil
+}
+
+// topGroups returns the groups saving the most bytes.
+func topGroups(records []*history.Record, by string) ([]history.Group, error) {
+	groups, err := history.Aggregate(records, by)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].SavedBytes > groups[j].SavedBytes })
+	return groups[:min(len(groups), maxGroups)], nil
+}
+
+// buildChart lays out one bar per day, or per month for spans over three
+// months, including periods without savings.
+func buildChart(successes []*history.Record, from, to time.Time) (Chart, error) {
+	c := Chart{Width: chartWidth, Height: chartHeight, Left: chartMargin, Top: chartMargin, Base: chartHeight - chartMargin, Period: "day"}
+	layout, step := "2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	start := time.Date(from.Local().Year(), from.Local().Month(), from.Local().Day(), 0, 0, 0, 0, time.Local)
+	if to.Sub(from) > 92*24*time.Hour {
+		c.Period = "month"
+		layout, step = "2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+
+	groups, err := history.Aggregate(successes, c.Period)
+	if err != nil {
+		return c, err
+	}
+	saved := make(map[string]int64, len(groups))
+	for _, g := range groups {
+		saved[g.Key] = g.SavedBytes
+		c.Max = max(c.Max, g.SavedBytes)
+	}
+
+	var keys []string
+	for t := start; !t.After(to); t = step(t) {
+		keys = append(keys, t.Format(layout))
+	}
+	plotW := float64(chartWidth - 2*chartMargin)
+	plotH := float64(chartHeight - 2*chartMargin)
+	slot := plotW / float64(len(keys))
+	labelEvery := max(1, len(keys)/8)
+	for i, key := range keys {
+		h := 0.0
+		if c.Max > 0 {
+			h = float64(saved[key]) / float64(c.Max) * plotH
+		}
+		c.Bars = append(c.Bars, Bar{
+			X:         chartMargin + float64(i)*slot + slot*0.1,
+			Y:         chartMargin + plotH - h,
+			W:         slot * 0.8,
+			H:         h,
+			Label:     key,
+			Saved:     saved[key],
+			ShowLabel: i%labelEvery == 0,
+		})
+	}
+	return c, nil
+}
+
+// WriteHTML renders the report as one HTML document without external assets.
+func WriteHTML(w io.Writer, r *Report) error {
+	return page.Execute(w, r)
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
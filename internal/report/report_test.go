@@ -0,0 +1,63 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+)
+
+func TestBuildAndRender(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.Local) }
+	records := []*history.Record{
+		{Timestamp: day(1), File: "/img/a.png", Status: history.StatusSuccess, BeforeSize: 1000, AfterSize: 400, SavedBytes: 600},
+		{Timestamp: day(3), File: "/img/b.png", Status: history.StatusSuccess, BeforeSize: 500, AfterSize: 400, SavedBytes: 100},
+		{Timestamp: day(4), File: "/img/<c>.jpg", Status: history.StatusFailed, Error: "<boom>"},
+	}
+	r, err := Build("Report", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Totals.SavedBytes != 700 || len(r.Chart.Bars) != 4 || r.Chart.Period != "day" {
+		t.Errorf("totals %+v, %d bars per %s", r.Totals, len(r.Chart.Bars), r.Chart.Period)
+	}
+	if r.Wins[0].File != "/img/a.png" || len(r.Failures) != 1 {
+		t.Errorf("wins %v, failures %v", r.Wins, r.Failures)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "<svg") || strings.Contains(out, "<boom>") || !strings.Contains(out, "&lt;boom&gt;") {
+		t.Error("report missing chart or not escaped")
+	}
+}
+
+func TestBuildListsLatestFailures(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	// Newest first, the order history queries return
+	var records []*history.Record
+	for i := maxFailures + 10; i > 0; i-- {
+		records = append(records, &history.Record{Timestamp: start.Add(time.Duration(i) * time.Hour), File: fmt.Sprintf("/img/%d.png", i), Status: history.StatusFailed})
+	}
+	r, err := Build("Report", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Failures) != maxFailures {
+		t.Fatalf("got %d failures, want %d", len(r.Failures), maxFailures)
+	}
+	for i, f := range r.Failures {
+		if want := fmt.Sprintf("/img/%d.png", maxFailures+10-i); f.File != want {
+			t.Fatalf("failure %d is %s, want %s", i, f.File, want)
+		}
+	}
+	if !r.From.Equal(start.Add(time.Hour)) || !r.To.Equal(start.Add((maxFailures+10)*time.Hour)) {
+		t.Errorf("range %v to %v", r.From, r.To)
+	}
+}
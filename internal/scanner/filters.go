@@ -0,0 +1,166 @@
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/imageinfo"
+)
+
+// FilterOptions narrow down which images a scan returns. Zero values disable
+// each filter.
+type FilterOptions struct {
+	LargerThan    int64     // Keep files bigger than this many bytes
+	SmallerThan   int64     // Keep files smaller than this many bytes
+	ModifiedSince time.Time // Keep files modified after this time
+
+	// Keep images within these pixel dimensions, read from their headers.
+	// Images whose dimensions can't be read are dropped.
+	MinWidth, MinHeight int
+	MaxWidth, MaxHeight int
+
+	// LastCompressed maps absolute paths to when they were last compressed.
+	// When set, files compressed since their last modification are dropped.
+	LastCompressed map[string]time.Time
+
+	GitChanged string // Keep files changed on this branch versus the given ref, or untracked
+	GitStaged  bool   // Keep files staged in the index
+}
+
+func (o FilterOptions) usesGit() bool {
+	return o.GitChanged != "" || o.GitStaged
+}
+
+func (o FilterOptions) usesDimensions() bool {
+	return o.MinWidth > 0 || o.MinHeight > 0 || o.MaxWidth > 0 || o.MaxHeight > 0
+}
+
+// keep applies the size, time, history and dimension filters to a file.
+func (o FilterOptions) keep(path string) bool {
+	if !o.keepFile(path) {
+		return false
+	}
+	if !o.usesDimensions() {
+		return true
+	}
+	info, err := imageinfo.Inspect(path)
+	if err != nil || info.Width == 0 {
+		return false
+	}
+	switch {
+	case o.MinWidth > 0 && info.Width < o.MinWidth,
+		o.MinHeight > 0 && info.Height < o.MinHeight,
+		o.MaxWidth > 0 && info.Width > o.MaxWidth,
+		o.MaxHeight > 0 && info.Height > o.MaxHeight:
+		return false
+	}
+	return true
+}
+
+// keepFile applies the filters answered by stat.
+func (o FilterOptions) keepFile(path string) bool {
+	if o.LargerThan == 0 && o.SmallerThan == 0 && o.ModifiedSince.IsZero() && o.LastCompressed == nil {
+		return true
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if o.LargerThan > 0 && info.Size() <= o.LargerThan {
+		return false
+	}
+	if o.SmallerThan > 0 && info.Size() >= o.SmallerThan {
+		return false
+	}
+	if !o.ModifiedSince.IsZero() && !info.ModTime().After(o.ModifiedSince) {
+		return false
+	}
+	if o.LastCompressed != nil {
+		if at, ok := o.LastCompressed[path]; ok && !info.ModTime().After(at) {
+			return false
+		}
+	}
+	return true
+}
+
+// gitFiles returns the absolute paths selected by the git filters, run in
+// the repository containing the working directory, with symlinks resolved.
+// Changed files are those differing between the working tree and the merge
+// base of GitChanged and HEAD, i.e. what the current branch touched, and
+// untracked files that aren't ignored.
+func (o FilterOptions) gitFiles() (map[string]bool, error) {
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(top))
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+
+	files := make(map[string]bool)
+	add := func(out []byte) {
+		for _, name := range bytes.Split(out, []byte{0}) {
+			if len(name) > 0 {
+				files[filepath.Join(root, filepath.FromSlash(string(name)))] = true
+			}
+		}
+	}
+
+	if o.GitChanged != "" {
+		base, err := git("merge-base", o.GitChanged, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		out, err := git("diff-index", "--name-only", "-z", "--diff-filter=d", strings.TrimSpace(string(base)), "--")
+		if err != nil {
+			return nil, err
+		}
+		add(out)
+		// New files the branch adds but hasn't committed yet
+		out, err = git("ls-files", "--others", "--exclude-standard", "--full-name", "-z", "--", ":/")
+		if err != nil {
+			return nil, err
+		}
+		add(out)
+	}
+	if o.GitStaged {
+		out, err := git("diff-index", "--cached", "--name-only", "-z", "--diff-filter=d", "HEAD", "--")
+		if err != nil {
+			return nil, err
+		}
+		add(out)
+	}
+	return files, nil
+}
+
+// realPath resolves symlinks in the directories of path, so it compares
+// equal to the paths from gitFiles; e.g. /var is /private/var on macOS. A
+// symlink named by path itself is kept, as git tracks the link.
+func realPath(path string) string {
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return path
+	}
+	return filepath.Join(dir, filepath.Base(path))
+}
+
+// git runs a git plumbing command in the working directory.
+func git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,220 @@
+package scanner
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// hasMeta reports whether p contains glob syntax.
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[{\`)
+}
+
+// expandBraces expands {a,b} alternatives, including nested ones:
+// "*.{png,jp{e,}g}" -> "*.png", "*.jpeg", "*.jpg". Braces without a comma
+// are kept literally.
+func expandBraces(p string) []string {
+	depth, start := 0, -1
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			alts := splitAlternatives(p[start+1 : i])
+			if len(alts) < 2 {
+				continue
+			}
+			var res []string
+			for _, alt := range alts {
+				res = append(res, expandBraces(p[:start]+alt+p[i+1:])...)
+			}
+			return res
+		}
+	}
+	return []string{p}
+}
+
+// splitAlternatives splits s on commas that are not nested in braces.
+func splitAlternatives(s string) []string {
+	var parts []string
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// splitGlob splits a brace-free pattern into the leading directory without
+// glob syntax and the remaining pattern, both slash separated.
+func splitGlob(pattern string) (base, rest string) {
+	segments := strings.Split(pattern, "/")
+	i := 0
+	for i < len(segments)-1 && !hasMeta(segments[i]) {
+		i++
+	}
+	base = strings.Join(segments[:i], "/")
+	if base == "" && strings.HasPrefix(pattern, "/") {
+		base = "/"
+	} else if base == "" {
+		base = "."
+	}
+	return base, strings.Join(segments[i:], "/")
+}
+
+// compileGlob compiles a brace-free pattern to an anchored regexp, where
+// ** spans directories and * ? [...] stay within one path segment.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + globToRegexp(pattern) + "$")
+}
+
+// globMatch is a path matching a glob, depth directory levels below the
+// glob's leading directory; 0 for patterns without glob syntax.
+type globMatch struct {
+	path  string
+	depth int
+}
+
+// expandGlob returns the files and directories matching pattern, which may
+// use **, {a,b} and the usual * ? [...] syntax. Directories pruned by the
+// ignore filter are not descended into, and with limit > 0 matches are at
+// most limit levels deep.
+func expandGlob(pattern string, f *filter, limit int) ([]globMatch, error) {
+	pattern = filepath.ToSlash(pattern)
+
+	var matches []globMatch
+	for _, p := range expandBraces(pattern) {
+		if !hasMeta(p) {
+			if _, err := os.Stat(filepath.FromSlash(p)); err == nil {
+				matches = append(matches, globMatch{path: filepath.FromSlash(p)})
+			}
+			continue
+		}
+
+		base, rest := splitGlob(p)
+		re, err := compileGlob(rest)
+		if err != nil {
+			return nil, err
+		}
+		// Without ** a match can't be deeper than the pattern
+		maxDepth := -1
+		if !strings.Contains(rest, "**") {
+			maxDepth = strings.Count(rest, "/") + 1
+		}
+		if limit > 0 && (maxDepth < 0 || limit < maxDepth) {
+			maxDepth = limit
+		}
+
+		root := filepath.FromSlash(base)
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			continue
+		}
+		f.enterRoot(absRoot)
+
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || path == root {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil
+			}
+			rel = filepath.ToSlash(rel)
+
+			abs := filepath.Join(absRoot, filepath.FromSlash(rel))
+			if f.ignored(abs, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			depth := strings.Count(rel, "/") + 1
+			if re.MatchString(rel) {
+				matches = append(matches, globMatch{path, depth})
+			}
+			if d.IsDir() {
+				if maxDepth > 0 && depth >= maxDepth {
+					return filepath.SkipDir
+				}
+				f.enterDir(abs)
+			}
+			return nil
+		})
+	}
+	return matches, nil
+}
+
+// negation is a "!pattern" scan argument removing matches from the result.
+type negation struct {
+	res []*regexp.Regexp
+	abs bool // Pattern is absolute, otherwise relative to the working directory
+}
+
+func compileNegation(pattern string) (*negation, error) {
+	pattern = filepath.ToSlash(strings.TrimPrefix(pattern, "!"))
+	pattern = strings.TrimPrefix(pattern, "./")
+	n := &negation{abs: filepath.IsAbs(filepath.FromSlash(pattern)) || strings.HasPrefix(pattern, "/")}
+	for _, p := range expandBraces(pattern) {
+		re, err := compileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		n.res = append(n.res, re)
+	}
+	return n, nil
+}
+
+// matches reports whether the absolute path, or a directory containing it,
+// is removed by the negation.
+func (n *negation) matches(abs, cwd string) bool {
+	target := filepath.ToSlash(abs)
+	if !n.abs {
+		rel, err := filepath.Rel(cwd, abs)
+		if err != nil {
+			return false
+		}
+		target = filepath.ToSlash(rel)
+	}
+	for {
+		for _, re := range n.res {
+			if re.MatchString(target) {
+				return true
+			}
+		}
+		i := strings.LastIndex(target, "/")
+		if i <= 0 {
+			return false
+		}
+		target = target[:i]
+	}
+}
@@ -0,0 +1,284 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IgnoreFileName is read from every scanned directory. It uses gitignore syntax.
+const IgnoreFileName = ".tinituiignore"
+
+// rule is a single gitignore-syntax pattern.
+type rule struct {
+	base     string // Absolute directory the pattern is relative to
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseRules compiles the lines of an ignore file located in base.
+func parseRules(base string, data string) []rule {
+	var rules []rule
+	for _, line := range strings.Split(data, "\n") {
+		if r, ok := compileRule(base, line); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// compileRule compiles one gitignore line. ok is false for blank lines and comments.
+func compileRule(base, line string) (rule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	r := rule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// A slash anywhere but the end anchors the pattern to base
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	prefix := "^(?:.*/)?"
+	if r.anchored {
+		prefix = "^"
+	}
+	re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp translates gitignore glob syntax (*, ?, [...], **) to a regexp body.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// matches reports whether the rule applies to the absolute path.
+func (r rule) matches(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasPrefix(rel, "../") {
+		if r.anchored {
+			return false
+		}
+		// Unanchored patterns match by name anywhere, even outside base
+		rel = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	}
+	return r.re.MatchString(rel)
+}
+
+// evalRules applies rules in order; the last matching rule wins.
+func evalRules(rules []rule, path string, isDir bool, ignored bool) bool {
+	for _, r := range rules {
+		if r.matches(path, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// filter decides which walked paths are kept. Rules from ignore files are
+// collected per directory; --exclude patterns are evaluated after them and
+// --include patterns restrict which files are kept at all.
+type filter struct {
+	exclude    []rule
+	include    []rule
+	gitignore  bool
+	skipHidden bool
+
+	mu       sync.RWMutex      // Directories are walked in parallel
+	dirRules map[string][]rule // Rules in effect inside each visited directory
+}
+
+func newFilter(opts Options) *filter {
+	f := &filter{
+		gitignore:  opts.Gitignore,
+		skipHidden: opts.SkipHidden,
+		dirRules:   make(map[string][]rule),
+	}
+	cwd, _ := os.Getwd()
+	ignoreBase := opts.IgnoreBase
+	if ignoreBase == "" {
+		ignoreBase = cwd
+	}
+	// Configured patterns come first so --exclude can override them
+	for _, p := range opts.Ignore {
+		if r, ok := compileRule(ignoreBase, p); ok {
+			f.exclude = append(f.exclude, r)
+		}
+	}
+	for _, p := range opts.Exclude {
+		if r, ok := compileRule(cwd, p); ok {
+			f.exclude = append(f.exclude, r)
+		}
+	}
+	for _, p := range opts.Include {
+		if r, ok := compileRule(cwd, p); ok {
+			f.include = append(f.include, r)
+		}
+	}
+	return f
+}
+
+// ignoreFiles lists the ignore file names read in each directory, lowest
+// precedence first.
+func (f *filter) ignoreFiles() []string {
+	if f.gitignore {
+		return []string{".gitignore", IgnoreFileName}
+	}
+	return []string{IgnoreFileName}
+}
+
+func (f *filter) loadDir(dir string) []rule {
+	var rules []rule
+	for _, name := range f.ignoreFiles() {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			rules = append(rules, parseRules(dir, string(data))...)
+		}
+	}
+	return rules
+}
+
+// enterRoot prepares the rules for a walk starting at root. Ignore files in
+// the ancestors of root are honoured up to the enclosing git repository.
+func (f *filter) enterRoot(root string) {
+	f.mu.RLock()
+	_, ok := f.dirRules[root]
+	f.mu.RUnlock()
+	if ok {
+		return
+	}
+	var ancestors []string
+	for dir := filepath.Dir(root); ; dir = filepath.Dir(dir) {
+		ancestors = append([]string{dir}, ancestors...)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			// Not inside a repository: only root's own ignore file applies
+			ancestors = nil
+			break
+		}
+	}
+
+	var rules []rule
+	for _, dir := range ancestors {
+		rules = append(rules, f.loadDir(dir)...)
+	}
+	rules = append(rules, f.loadDir(root)...)
+
+	f.mu.Lock()
+	f.dirRules[root] = rules
+	f.mu.Unlock()
+}
+
+// enterDir records the rules in effect inside dir, whose parent was already visited.
+func (f *filter) enterDir(dir string) {
+	f.mu.RLock()
+	parent := f.dirRules[filepath.Dir(dir)]
+	f.mu.RUnlock()
+	rules := append(append([]rule(nil), parent...), f.loadDir(dir)...)
+
+	f.mu.Lock()
+	f.dirRules[dir] = rules
+	f.mu.Unlock()
+}
+
+// ignored reports whether a walked path is excluded by ignore files,
+// --exclude or the hidden file setting.
+func (f *filter) ignored(path string, isDir bool) bool {
+	name := filepath.Base(path)
+	if isDir && name == ".git" {
+		return true
+	}
+	if f.skipHidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+	f.mu.RLock()
+	rules := f.dirRules[filepath.Dir(path)]
+	f.mu.RUnlock()
+	ignored := evalRules(rules, path, isDir, false)
+	return evalRules(f.exclude, path, isDir, ignored)
+}
+
+// included reports whether a file passes --exclude and --include. It is used
+// for files named directly, where ignore files don't apply.
+func (f *filter) included(path string) bool {
+	if evalRules(f.exclude, path, false, false) {
+		return false
+	}
+	return f.wanted(path)
+}
+
+// wanted applies the --include patterns to a file.
+func (f *filter) wanted(path string) bool {
+	if len(f.include) == 0 {
+		return true
+	}
+	return evalRules(f.include, path, false, false)
+}
@@ -1,122 +1,287 @@
 package scanner
 
 import (
+	"context"
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+
+	"github.com/gmsakibursabbir/tinitui/internal/imageinfo"
 )
 
+// SupportedExtensions are the image extensions. Files without one are judged
+// by content, so extension-less uploads and renamed copies are found too.
 var SupportedExtensions = map[string]bool{
 	".png":  true,
 	".jpg":  true,
 	".jpeg": true,
 	".webp": true,
+	".avif": true,
 }
 
 // ScanResults holds the found files and any errors encountered (permissions etc)
 type ScanResults struct {
-	Images []string
-	Errors []error
+	Images   []string
+	Errors   []error
+	Warnings []string // Files whose extension doesn't match their content
+}
+
+// Options control how Scan walks directories.
+type Options struct {
+	Recursive bool
+	Exclude   []string // gitignore-syntax patterns to leave out
+	Include   []string // If set, only files matching one of these are kept
+	Gitignore bool     // Also honour .gitignore files, not just .tinituiignore
+	Filters   FilterOptions
+
+	// Ignore holds patterns from the configuration, relative to IgnoreBase
+	// (the working directory when empty). They apply like --exclude.
+	Ignore     []string
+	IgnoreBase string
+
+	MaxDepth   int           // Directory levels to descend into, 0 for no limit; 1 is the directory itself
+	Symlinks   SymlinkPolicy // What to do with symbolic links found while walking
+	SkipHidden bool          // Leave out files and directories starting with a dot
+	Sorted     bool          // Stream results in a deterministic order, reading one directory at a time
+	Workers    int           // Directories read in parallel, 0 picks a default
+}
+
+// Result is one item produced by Stream: an image path, a warning about a
+// found image, or an error encountered while scanning. Only one field is set.
+type Result struct {
+	Path    string
+	Warning string
+	Err     error
 }
 
 // ScanFiles scans the given paths for images.
 // If a path is a directory and recursive is true, it walks the directory.
 // If a path is a glob pattern, it expands it.
 func Scan(paths []string, recursive bool) (*ScanResults, error) {
-	uniquePaths := make(map[string]bool)
-	var errors []error
+	return ScanWithOptions(paths, Options{Recursive: recursive})
+}
 
+// ScanWithOptions is Scan with ignore-file handling and include/exclude
+// patterns. Directories matched by an ignore rule are not descended into.
+// It waits for the whole scan and returns the images sorted.
+func ScanWithOptions(paths []string, opts Options) (*ScanResults, error) {
+	results, err := Stream(context.Background(), paths, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ScanResults{}
+	for r := range results {
+		switch {
+		case r.Err != nil:
+			res.Errors = append(res.Errors, r.Err)
+		case r.Warning != "":
+			res.Warnings = append(res.Warnings, r.Warning)
+		default:
+			res.Images = append(res.Images, r.Path)
+		}
+	}
+	sort.Strings(res.Images)
+	return res, nil
+}
+
+// Stream scans like ScanWithOptions but sends images as they are found, so
+// work can start before a large tree is fully walked. Directories are read
+// in parallel unless opts.Sorted is set. The channel is closed when the scan
+// finishes or ctx is cancelled. Each image is sent once.
+func Stream(ctx context.Context, paths []string, opts Options) (<-chan Result, error) {
+	var gitFiles map[string]bool
+	if opts.Filters.usesGit() {
+		var err error
+		if gitFiles, err = opts.Filters.gitFiles(); err != nil {
+			return nil, err
+		}
+	}
+
+	// "!pattern" arguments remove matches of the other arguments
+	var negations []*negation
+	var positives []string
+	var patternErrs []error
 	for _, p := range paths {
-		// Handle Glob
-		matches, err := filepath.Glob(p)
+		if !strings.HasPrefix(p, "!") {
+			positives = append(positives, p)
+			continue
+		}
+		n, err := compileNegation(p)
 		if err != nil {
-			// If glob fails, assume it's a direct path (it might be a file with * in name, rare but possible, 
-			// or just invalid glob syntax). Treat as literal path if glob failed?
-			// filepath.Glob returns error only on BadPattern.
-			errors = append(errors, fmt.Errorf("glob error %s: %w", p, err))
+			patternErrs = append(patternErrs, fmt.Errorf("glob error %s: %w", p, err))
 			continue
 		}
+		negations = append(negations, n)
+	}
+
+	out := make(chan Result, 256)
+	send := func(r Result) {
+		select {
+		case out <- r:
+		case <-ctx.Done():
+		}
+	}
+
+	cwd, _ := os.Getwd()
+	var seen sync.Map
 
-		if matches == nil {
-			// No matches, might be a direct file that hasn't been created yet? 
-			// Or just a specific file path that Glob didn't match (e.g. absolute path without special chars? Glob matches those too).
-			// If no match, check if exact file exists.
-			if _, err := os.Stat(p); err == nil {
-				matches = []string{p}
+	// file applies the negations and filters, sniffs path and sends it along
+	// with any extension mismatch warning
+	file := func(path string) {
+		if !candidate(path) {
+			return
+		}
+		for _, n := range negations {
+			if n.matches(path, cwd) {
+				return
 			}
 		}
+		if gitFiles != nil && !gitFiles[realPath(path)] {
+			return
+		}
+		if _, dup := seen.LoadOrStore(path, true); dup {
+			return
+		}
+		if !opts.Filters.keep(path) {
+			return
+		}
+		format, sniffed := classify(path)
+		if format == "" {
+			return
+		}
+		if sniffed {
+			send(Result{Warning: mismatchWarning(path, format)})
+		}
+		send(Result{Path: path})
+	}
+
+	filter := newFilter(opts)
+	w := newWalker(ctx, filter, opts)
+	w.file = file
+	w.fail = func(err error) { send(Result{Err: err}) }
+
+	go func() {
+		defer close(out)
+		for _, err := range patternErrs {
+			send(Result{Err: err})
+		}
 
-		for _, match := range matches {
-			info, err := os.Stat(match)
+		for _, p := range positives {
+			if ctx.Err() != nil {
+				break
+			}
+			// Handle Glob (with ** and {a,b} support)
+			matches, err := expandGlob(p, filter, opts.MaxDepth)
 			if err != nil {
-				errors = append(errors, err)
+				// If glob fails, assume it's a direct path (it might be a file with * in name, rare but possible, 
+				// or just invalid glob syntax). Treat as literal path if glob failed?
+				// filepath.Glob returns error only on BadPattern.
+				send(Result{Err: fmt.Errorf("glob error %s: %w", p, err)})
 				continue
 			}
 
-			if info.IsDir() {
-				if recursive {
-					// Walk
-					err := filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
-						if err != nil {
-							// Permission denied etc, log and continue
-							// We don't want to stop the whole walk for one file
-							return nil 
-						}
-						if !d.IsDir() && isSupported(path) {
-							abs, err := filepath.Abs(path)
-							if err == nil {
-								uniquePaths[abs] = true
-							}
-						}
-						return nil
-					})
-					if err != nil {
-						errors = append(errors, fmt.Errorf("walk error %s: %w", match, err))
-					}
-				} else {
-					// Directory but not recursive
-					// The prompt says "Enter open directory" in TUI. 
-					// For CLI "paths...", do we include only top level images?
-				    // "Options: recursive" implies default might be non-recursive for folders? 
-					// Let's assume just scan top level files if not recursive.
-					entries, err := os.ReadDir(match)
-					if err != nil {
-						errors = append(errors, err)
-						continue
-					}
-					for _, entry := range entries {
-						if !entry.IsDir() && isSupported(entry.Name()) {
-							fullPath := filepath.Join(match, entry.Name())
-							abs, err := filepath.Abs(fullPath)
-							if err == nil {
-								uniquePaths[abs] = true
-							}
-						}
-					}
+			if matches == nil {
+				// No matches, might be a direct file that hasn't been created yet? 
+				// Or just a specific file path that Glob didn't match (e.g. absolute path without special chars? Glob matches those too).
+				// If no match, check if exact file exists.
+				if _, err := os.Stat(p); err == nil {
+					matches = []globMatch{{path: p}}
+				}
+			}
+
+			for _, m := range matches {
+				match := m.path
+				info, err := os.Stat(match)
+				if err != nil {
+					send(Result{Err: err})
+					continue
 				}
-			} else {
-				// File
-				if isSupported(match) {
-					abs, err := filepath.Abs(match)
-					if err == nil {
-						uniquePaths[abs] = true
+
+				abs, err := filepath.Abs(match)
+				if err != nil {
+					send(Result{Err: err})
+					continue
+				}
+				if info.IsDir() {
+					// Without Recursive only the directory's own files are scanned.
+					// MaxDepth counts from the glob's leading directory, so a
+					// matched directory has fewer levels left
+					start := 1
+					if opts.MaxDepth > 0 {
+						if m.depth >= opts.MaxDepth {
+							continue
+						}
+						start = m.depth + 1
 					}
+					filter.enterRoot(abs)
+					w.walk(abs, start)
+				} else if filter.included(abs) {
+					file(abs)
 				}
 			}
 		}
+		w.wait()
+	}()
+
+	return out, nil
+}
+
+// Detect returns the image format of path judged by its content, and whether
+// the file extension disagrees with it. format is "" for files that are not
+// supported images. Files that can't be read are judged by extension only.
+func Detect(path string) (format string, mismatch bool) {
+	extFormat := imageinfo.FormatForExt(filepath.Ext(path))
+	format, err := imageinfo.SniffFile(path)
+	if err != nil {
+		return extFormat, false
+	}
+	return format, format != "" && format != extFormat
+}
+
+// candidate reports whether path may be an image judging by its name alone:
+// it has an image extension, none at all, or one appended to an image
+// extension (photo.JPG.bak). Other files are never read.
+func candidate(path string) bool {
+	base := filepath.Base(path)
+	ext := strings.ToLower(filepath.Ext(base))
+	if ext == "" || SupportedExtensions[ext] {
+		return true
 	}
+	return SupportedExtensions[strings.ToLower(filepath.Ext(strings.TrimSuffix(base, filepath.Ext(base))))]
+}
 
-	images := make([]string, 0, len(uniquePaths))
-	for p := range uniquePaths {
-		images = append(images, p)
+// classify returns the format of a candidate, taken from an image extension
+// without reading the file, and otherwise sniffed from the content. sniffed
+// reports the latter, where the name doesn't tell the format.
+func classify(path string) (format string, sniffed bool) {
+	if format := imageinfo.FormatForExt(filepath.Ext(path)); format != "" {
+		return format, false
+	}
+	format, err := imageinfo.SniffFile(path)
+	if err != nil {
+		return "", false
 	}
+	return format, format != ""
+}
 
-	return &ScanResults{Images: images, Errors: errors}, nil
+// IsImage reports whether path is a supported image: by its extension, or
+// by content for files without an image extension.
+func IsImage(path string) bool {
+	if !candidate(path) {
+		return false
+	}
+	format, _ := classify(path)
+	return format != ""
 }
 
-func isSupported(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	return SupportedExtensions[ext]
+func mismatchWarning(path, format string) string {
+	ext := filepath.Ext(path)
+	if !SupportedExtensions[strings.ToLower(ext)] {
+		return fmt.Sprintf("%s: detected %s content without an image extension", path, strings.ToUpper(format))
+	}
+	return fmt.Sprintf("%s: extension %s but content is %s", path, ext, strings.ToUpper(format))
 }
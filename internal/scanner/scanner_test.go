@@ -0,0 +1,353 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"image"
+	pngenc "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gmsakibursabbir/tinitui/internal/config"
+	"github.com/gmsakibursabbir/tinitui/internal/pipeline"
+)
+
+// png is enough of a PNG header for content sniffing.
+const png = "\x89PNG\r\n\x1a\n"
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func relImages(t *testing.T, root string, res *ScanResults) []string {
+	t.Helper()
+	var rel []string
+	for _, p := range res.Images {
+		r, err := filepath.Rel(root, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	sort.Strings(rel)
+	return rel
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCompileRule(t *testing.T) {
+	base := filepath.FromSlash("/repo")
+	cases := []struct {
+		pattern, path string
+		isDir, want   bool
+	}{
+		{"*.png", "/repo/a/b.png", false, true},
+		{"*.png", "/repo/a/b.jpg", false, false},
+		{"node_modules/", "/repo/web/node_modules", true, true},
+		{"node_modules/", "/repo/web/node_modules", false, false},
+		{"/build", "/repo/build", true, true},
+		{"/build", "/repo/src/build", true, false},
+		{"assets/**/raw", "/repo/assets/x/y/raw", true, true},
+		{"assets/**/raw", "/repo/assets/raw", true, true},
+		{"**/tmp", "/repo/a/tmp", true, true},
+		{"icon-?.png", "/repo/icon-1.png", false, true},
+		{"icon-[!0-9].png", "/repo/icon-1.png", false, false},
+	}
+	for _, c := range cases {
+		r, ok := compileRule(base, c.pattern)
+		if !ok {
+			t.Fatalf("%q did not compile", c.pattern)
+		}
+		if got := r.matches(filepath.FromSlash(c.path), c.isDir); got != c.want {
+			t.Errorf("%q vs %q: got %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestScanHonoursIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".tinituiignore":            "vendor/\n*.raw.png\n",
+		".gitignore":                "dist/\n",
+		"a.png":                     png,
+		"b.raw.png":                 png,
+		"vendor/lib.png":            png,
+		"dist/out.png":              png,
+		"docs/.tinituiignore":       "*.jpg\n!keep.jpg\n",
+		"docs/x.jpg":                png,
+		"docs/keep.jpg":             png,
+		"node_modules/pkg/logo.png": png,
+	})
+
+	res, err := ScanWithOptions([]string{root}, Options{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.png", "dist/out.png", "docs/keep.jpg", "node_modules/pkg/logo.png"}
+	if got := relImages(t, root, res); !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	res, err = ScanWithOptions([]string{root}, Options{
+		Recursive: true,
+		Gitignore: true,
+		Exclude:   []string{"node_modules/"},
+		Include:   []string{"*.png"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"a.png"}
+	if got := relImages(t, root, res); !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	got := expandBraces("img/*.{png,jp{e,}g}")
+	want := []string{"img/*.png", "img/*.jpeg", "img/*.jpg"}
+	if !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := expandBraces("{single}.png"); !equal(got, []string{"{single}.png"}) {
+		t.Errorf("literal braces: got %v", got)
+	}
+}
+
+func TestScanDoublestarGlobs(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"assets/a.png":             png,
+		"assets/icons/b.png":       png,
+		"assets/icons/deep/c.jpg":  png,
+		"assets/icons/deep/d.webp": png,
+		"assets/skip/e.png":        png,
+		"other/f.png":              png,
+	})
+	wd, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	res, err := ScanWithOptions([]string{"assets/**/*.{png,jpg}", "!assets/skip"}, Options{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"assets/a.png", "assets/icons/b.png", "assets/icons/deep/c.jpg"}
+	if got := relImages(t, root, res); !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	res, err = ScanWithOptions([]string{"assets/*/*.png"}, Options{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"assets/icons/b.png", "assets/skip/e.png"}
+	if got := relImages(t, root, res); !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestScanSniffsContent(t *testing.T) {
+	root := t.TempDir()
+	var encoded bytes.Buffer
+	if err := pngenc.Encode(&encoded, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, map[string]string{
+		"real.png":      png,
+		"photo.JPG.bak": "\xff\xd8\xff\xe0",
+		"upload123":     "RIFF\x00\x00\x00\x00WEBPVP8 ",
+		"fake.jpg":      encoded.String(),
+		"notes.txt":     png,
+	})
+
+	res, err := ScanWithOptions([]string{root}, Options{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Files with an image extension are taken at their word, other
+	// extensions aren't read
+	want := []string{"fake.jpg", "photo.JPG.bak", "real.png", "upload123"}
+	if got := relImages(t, root, res); !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The scanner warns about the names that don't tell the format, the
+	// preflight of a run or plan about the renamed fake.jpg
+	warnings := res.Warnings
+	for _, e := range pipeline.BuildPlan(config.DefaultConfig(), res.Images, nil).Entries {
+		if e.Warning != "" {
+			warnings = append(warnings, e.Input+": "+e.Warning)
+		}
+	}
+	if len(warnings) != 3 {
+		t.Errorf("expected 3 mismatch warnings, got %v", warnings)
+	}
+}
+
+func TestScanFilters(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"small.png": png,
+		"big.png":   png + string(make([]byte, 2048)),
+		"old.png":   png + string(make([]byte, 2048)),
+	})
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "old.png"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ScanWithOptions([]string{root}, Options{
+		Recursive: true,
+		Filters: FilterOptions{
+			LargerThan:    1024,
+			ModifiedSince: time.Now().Add(-24 * time.Hour),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relImages(t, root, res), []string{"big.png"}; !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	res, err = ScanWithOptions([]string{root}, Options{
+		Recursive: true,
+		Filters: FilterOptions{
+			LastCompressed: map[string]time.Time{filepath.Join(root, "small.png"): time.Now().Add(time.Hour)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relImages(t, root, res), []string{"big.png", "old.png"}; !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestStreamWalkOptions(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.png":          png,
+		"sub/b.png":      png,
+		"sub/deep/c.png": png,
+		".hidden/d.png":  png,
+		"sub/.e.png":     png,
+	})
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		opts    Options
+		want    []string
+	}{
+		{"all", "", Options{Recursive: true}, []string{".hidden/d.png", "a.png", "sub/.e.png", "sub/b.png", "sub/deep/c.png"}},
+		{"max depth", "", Options{Recursive: true, MaxDepth: 2, SkipHidden: true}, []string{"a.png", "sub/b.png"}},
+		{"not recursive", "", Options{}, []string{"a.png"}},
+		{"follow links", "", Options{Recursive: true, Symlinks: SymlinksFollow, SkipHidden: true}, []string{"a.png", "sub/b.png", "sub/deep/c.png"}},
+		{"glob max depth", "**", Options{Recursive: true, MaxDepth: 2, SkipHidden: true, Symlinks: SymlinksSkip}, []string{"a.png", "sub/b.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Stream(context.Background(), []string{filepath.Join(root, tt.pattern)}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := &ScanResults{}
+			for r := range results {
+				if r.Path != "" {
+					res.Images = append(res.Images, r.Path)
+				}
+			}
+			if got := relImages(t, root, res); !equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanDimensionFilters(t *testing.T) {
+	root := t.TempDir()
+	for name, size := range map[string]int{"small.png": 10, "medium.png": 50, "large.png": 200} {
+		var buf bytes.Buffer
+		if err := pngenc.Encode(&buf, image.NewGray(image.Rect(0, 0, size, size))); err != nil {
+			t.Fatal(err)
+		}
+		writeFiles(t, root, map[string]string{name: buf.String()})
+	}
+	writeFiles(t, root, map[string]string{"broken.png": png})
+
+	res, err := ScanWithOptions([]string{root}, Options{
+		Recursive: true,
+		Filters:   FilterOptions{MinWidth: 20, MaxHeight: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relImages(t, root, res), []string{"medium.png"}; !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestScanGitChanged(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	writeFiles(t, repo, map[string]string{"kept.png": png, "edited.png": png, ".gitignore": "ignored.png\n"})
+	t.Chdir(repo)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	writeFiles(t, repo, map[string]string{"edited.png": png + "x", "new/added.png": png, "ignored.png": png})
+
+	// Scanned through a link, so paths differ from git's until resolved
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(repo, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	res, err := ScanWithOptions([]string{link}, Options{
+		Recursive: true,
+		Filters:   FilterOptions{GitChanged: "HEAD"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relImages(t, link, res), []string{"edited.png", "new/added.png"}; !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
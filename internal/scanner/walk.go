@@ -0,0 +1,148 @@
+package scanner
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// SymlinkPolicy decides what a directory walk does with symbolic links.
+type SymlinkPolicy string
+
+const (
+	// SymlinksFiles keeps links to files but doesn't descend into linked
+	// directories. It is the default.
+	SymlinksFiles SymlinkPolicy = ""
+	// SymlinksFollow also descends into linked directories. Each directory
+	// is walked once, so links pointing back up the tree don't loop.
+	SymlinksFollow SymlinkPolicy = "follow"
+	// SymlinksSkip ignores all links.
+	SymlinksSkip SymlinkPolicy = "skip"
+)
+
+// walker reads directories in parallel, bounded by sem. When sem is nil every
+// directory is read inline, which yields a deterministic depth-first order.
+type walker struct {
+	ctx      context.Context
+	filter   *filter
+	symlinks SymlinkPolicy
+	maxDepth int               // Deepest entry level considered, 0 for no limit
+	file     func(path string) // Called for every kept file
+	fail     func(err error)   // Called for directories that can't be read
+	sem      chan struct{}
+
+	wg      sync.WaitGroup
+	visited sync.Map // Real paths of walked directories, when following links
+}
+
+func newWalker(ctx context.Context, f *filter, opts Options) *walker {
+	w := &walker{
+		ctx:      ctx,
+		filter:   f,
+		symlinks: opts.Symlinks,
+		maxDepth: opts.MaxDepth,
+	}
+	if !opts.Recursive {
+		w.maxDepth = 1
+	}
+	if !opts.Sorted {
+		workers := opts.Workers
+		if workers <= 0 {
+			workers = max(4, runtime.NumCPU())
+		}
+		w.sem = make(chan struct{}, workers)
+	}
+	return w
+}
+
+// walk visits the directory root, whose ignore rules are already loaded and
+// whose entries sit at the given depth. Subdirectories may still be read in
+// the background; call wait.
+func (w *walker) walk(root string, depth int) {
+	if w.symlinks == SymlinksFollow && !w.visit(root) {
+		return
+	}
+	w.walkDir(root, depth)
+}
+
+func (w *walker) wait() {
+	w.wg.Wait()
+}
+
+// visit reports whether dir hasn't been walked yet and marks it walked.
+func (w *walker) visit(dir string) bool {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	_, seen := w.visited.LoadOrStore(real, true)
+	return !seen
+}
+
+// walkDir handles the entries of dir, which sit at the given depth below the root.
+func (w *walker) walkDir(dir string, depth int) {
+	if w.ctx.Err() != nil {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		// Permission denied etc: report it and carry on with what was read
+		w.fail(err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		isDir := e.IsDir()
+
+		if e.Type()&fs.ModeSymlink != 0 {
+			if w.symlinks == SymlinksSkip {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				continue // Dangling link
+			}
+			isDir = info.IsDir()
+			if isDir && w.symlinks != SymlinksFollow {
+				continue
+			}
+		}
+
+		if w.filter.ignored(path, isDir) {
+			continue
+		}
+		if !isDir {
+			if w.filter.wanted(path) {
+				w.file(path)
+			}
+			continue
+		}
+		if w.maxDepth > 0 && depth >= w.maxDepth {
+			continue
+		}
+		if w.symlinks == SymlinksFollow && !w.visit(path) {
+			continue
+		}
+		w.filter.enterDir(path)
+		w.spawn(path, depth+1)
+	}
+}
+
+// spawn walks dir on a new goroutine if a slot is free, otherwise inline.
+func (w *walker) spawn(dir string, depth int) {
+	select {
+	case w.sem <- struct{}{}:
+		w.wg.Add(1)
+		go func() {
+			defer func() {
+				<-w.sem
+				w.wg.Done()
+			}()
+			w.walkDir(dir, depth)
+		}()
+	default:
+		w.walkDir(dir, depth)
+	}
+}
@@ -34,6 +34,21 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("api error %d (%s): %s", e.StatusCode, e.Type, e.Message)
 }
 
+// retriesKey is the context key of the counter set by WithRetryCounter.
+type retriesKey struct{}
+
+// WithRetryCounter returns a context in which Compress adds the number of
+// requests it had to retry to *n.
+func WithRetryCounter(ctx context.Context, n *int) context.Context {
+	return context.WithValue(ctx, retriesKey{}, n)
+}
+
+func countRetry(ctx context.Context) {
+	if n, ok := ctx.Value(retriesKey{}).(*int); ok {
+		*n++
+	}
+}
+
 func NewClient(apiKey string) *Client {
 	return &Client{
 		APIKey: apiKey,
@@ -114,17 +129,15 @@ func (c *Client) doShrinkWithRetry(ctx context.Context, payload []byte) (*shrink
 
 	for i := 0; i <= maxRetries; i++ {
 		if i > 0 {
-			msg := fmt.Sprintf("Retrying upload... (%d/%d)", i, maxRetries)
-			// We can maybe log this or have a callback, for now just sleep
+			// Retries are reported through WithRetryCounter; printing here
+			// would corrupt the TUI and machine-readable CLI output
+			countRetry(ctx)
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
 			case <-time.After(baseDelay * time.Duration(1<<i)):
 				// exponential backoff
 			}
-			// In TUI, we might want to signal "Retrying" via channel or status.
-			// Ideally pipeline handles this via error or specific callback.
-			fmt.Println(msg)
 		}
 
 		req, err := http.NewRequestWithContext(ctx, "POST", APIURL, bytes.NewReader(payload))
@@ -136,6 +149,9 @@ func (c *Client) doShrinkWithRetry(ctx context.Context, payload []byte) (*shrink
 
 		resp, err := c.Client.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			// Network failure, retry
 			continue
 		}
@@ -176,6 +192,7 @@ func (c *Client) downloadWithRetry(ctx context.Context, url string) (io.ReadClos
 
 	for i := 0; i <= maxRetries; i++ {
 		if i > 0 {
+			countRetry(ctx)
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
@@ -190,6 +207,9 @@ func (c *Client) downloadWithRetry(ctx context.Context, url string) (io.ReadClos
 
 		resp, err := c.Client.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			continue
 		}
 
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
@@ -143,9 +144,7 @@ func (b *browserModel) scanDirectory() {
 			continue
 		}
 		
-		ext := strings.ToLower(filepath.Ext(e.Name()))
-		switch ext {
-		case ".jpg", ".jpeg", ".png", ".webp":
+		if scanner.IsImage(filepath.Join(b.currentDir, e.Name())) {
 			filtered = append(filtered, e)
 		}
 	}
@@ -533,13 +532,21 @@ func (m MainModel) updateBrowser(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			
 			if len(paths) > 0 {
-				scannerJobs, _ := scanner.Scan(paths, m.browser.recursive)
-				if len(scannerJobs.Images) > 0 {
-					m.pipeline.AddFiles(scannerJobs.Images)
+				// Stream the scan so large folders don't freeze the UI; files
+				// are queued in batches as they are found
+				results, err := scanner.Stream(context.Background(), paths, scanner.Options{
+					Recursive:  m.browser.recursive,
+					Ignore:     m.config.Ignore,
+					IgnoreBase: m.config.ProjectDir(),
+				})
+				if err == nil {
+					m.queue.scanning = true
+					m.queue.scanned = 0
+					m.queue.found = nil
 					m.state = StateQueue
-					m.queue.Sync(m.pipeline.Jobs())
 					m.browser.selected = make(map[string]bool)
 					m.browser.updateListItems()
+					return m, waitForScan(results)
 				}
 			}
 		
@@ -695,3 +702,31 @@ func (m MainModel) viewBrowser() string {
 		statusBar,
 	)
 }
+
+// scanBatchMsg carries images found by a streaming scan.
+type scanBatchMsg struct {
+	results <-chan scanner.Result
+	paths   []string
+	done    bool
+}
+
+// waitForScan waits for the next images from a scan and takes whatever else
+// is already available, so the queue is updated in batches.
+func waitForScan(results <-chan scanner.Result) tea.Cmd {
+	return func() tea.Msg {
+		msg := scanBatchMsg{results: results}
+		for {
+			r, ok := <-results
+			if !ok {
+				msg.done = true
+				return msg
+			}
+			if r.Path != "" {
+				msg.paths = append(msg.paths, r.Path)
+			}
+			if len(msg.paths) > 0 && len(results) == 0 {
+				return msg
+			}
+		}
+	}
+}
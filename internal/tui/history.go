@@ -19,6 +19,7 @@ type historyModel struct {
 func newHistoryModel() historyModel {
 	columns := []table.Column{
 		{Title: "Time", Width: 20},
+		{Title: "Status", Width: 8},
 		{Title: "File", Width: 30},
 		{Title: "Before", Width: 10},
 		{Title: "After", Width: 10},
@@ -49,6 +50,7 @@ func (h *historyModel) refresh() {
 		r := recs[i]
 		rows = append(rows, table.Row{
 			r.Timestamp.Format("2006-01-02 15:04"),
+			r.Status,
 			filepath.Base(r.File),
 			fmt.Sprintf("%d", r.BeforeSize),
 			fmt.Sprintf("%d", r.AfterSize),
@@ -16,7 +16,7 @@ func getIcon(name string, isDir bool) string {
 	// Images
 	case ".png", ".jpg", ".jpeg":
 		return "🖼️ "
-	case ".webp", ".gif", ".bmp", ".tiff":
+	case ".webp", ".avif", ".gif", ".bmp", ".tiff":
 		return "🎨"
 	case ".svg":
 		return "✒️ "
@@ -2,11 +2,13 @@ package tui
 
 import (
 	"fmt"
+	"sort"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/gmsakibursabbir/tinitui/internal/config"
+	"github.com/gmsakibursabbir/tinitui/internal/history"
 	"github.com/gmsakibursabbir/tinitui/internal/pipeline"
 	"github.com/gmsakibursabbir/tinitui/internal/version"
 )
@@ -20,6 +22,7 @@ const (
 	StateCompress
 	StateHistory
 	StateSettings
+	StatePlan
 )
 
 var (
@@ -40,6 +43,7 @@ type MainModel struct {
 	progress    progressModel
 	history     historyModel
 	settings    settingsModel
+	plan        planModel
 	
 	showingHelp bool
 	width  int
@@ -65,6 +69,11 @@ func InitialModel(cfg *config.Config) MainModel {
 	}
 	
 	m.pipeline = pipeline.New(cfg, cfg.APIKey)
+	m.pipeline.Configure(cfg.Concurrency)
+	if m.history.mgr != nil {
+		m.history.mgr.SetRetention(cfg.History)
+		m.pipeline.SetHistory(m.history.mgr, history.SourceTUI)
+	}
 	
 	return m
 }
@@ -98,6 +107,9 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// If we have jobs, start
 			if len(m.pipeline.Jobs()) > 0 {
 				m.state = StateCompress
+				m.progress.active = true
+				m.progress.done = false
+				m.progress.hookErr = nil
 				m.pipeline.Start() // Or ensure started
 			}
 		case "s":
@@ -133,6 +145,28 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+	case runCompleteMsg:
+		if msg.err != nil {
+			m.progress.hookErr = msg.err
+		}
+	case scanBatchMsg:
+		// Outputs of different inputs can only collide when they are renamed
+		// or gathered in one directory. Then the whole scan is queued at once
+		// in sorted order like the CLI does, so the same input wins.
+		m.queue.found = append(m.queue.found, msg.paths...)
+		m.queue.scanned += len(msg.paths)
+		collisions := m.config.OutputDir != "" || m.config.FixExtensions
+		if len(m.queue.found) > 0 && (msg.done || !collisions) {
+			sort.Strings(m.queue.found)
+			m.pipeline.AddFiles(m.queue.found)
+			m.queue.found = nil
+			m.queue.Sync(m.pipeline.Jobs())
+		}
+		if msg.done {
+			m.queue.scanning = false
+			return m, nil
+		}
+		return m, waitForScan(msg.results)
 	}
 	
 	var cmd tea.Cmd
@@ -169,6 +203,10 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		newModel, newCmd := m.updateSettings(msg)
 		m = newModel.(MainModel)
 		cmd = newCmd
+	case StatePlan:
+		newModel, newCmd := m.updatePlan(msg)
+		m = newModel.(MainModel)
+		cmd = newCmd
 	}
 	
 	// Handle pipeline updates globally if needed, or ensure waitForPipeline is re-dispatched
@@ -226,6 +264,8 @@ func (m MainModel) View() string {
 		content = m.viewHistory()
 	case StateSettings:
 		content = m.viewSettings()
+	case StatePlan:
+		content = m.viewPlan()
 	default:
 		content = fmt.Sprintf("State: %v", m.state)
 	}
@@ -251,7 +291,8 @@ func (m MainModel) View() string {
 			"  [:] Command     [p] Preview\n" +
 			"  [s] Sort        [S] Sort Dir\n\n" +
 			" Queue:\n" +
-			"  [d] Remove      [c] Clear",
+			"  [d] Remove      [c] Clear\n" +
+			"  [p] Plan (dry run)",
 		)
 		
 		// Center overlay
@@ -319,7 +360,12 @@ func (m MainModel) renderTopBar() string {
 }
 
 func (m MainModel) renderBottomBar() string {
-	return styleDim.Render("A: Add Files | R: Run | S: Settings | H: History | Q: Quit")
+	keys := styleDim.Render("A: Add Files | R: Run | S: Settings | H: History | Q: Quit")
+	// Hook failures are shown on every view, as a finished run switches to History
+	if m.progress.hookErr != nil {
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(ColorRed)).Render("Hook failed: "+m.progress.hookErr.Error()) + "\n" + keys
+	}
+	return keys
 }
 
 // ---------------- STUBS -----------------
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/gmsakibursabbir/tinitui/internal/config"
+	"github.com/gmsakibursabbir/tinitui/internal/history"
+	"github.com/gmsakibursabbir/tinitui/internal/pipeline"
+)
+
+// planModel shows a dry run of the pending queue: where every file would be
+// written, what would be skipped and the estimated savings.
+type planModel struct {
+	table table.Model
+	plan  *pipeline.Plan
+}
+
+func newPlanModel(cfg *config.Config, jobs []*pipeline.Job, mgr *history.Manager) planModel {
+	var paths []string
+	for _, j := range jobs {
+		if j.Status == pipeline.StatusPending {
+			paths = append(paths, j.FilePath)
+		}
+	}
+
+	var ratios map[string]float64
+	if mgr != nil {
+		ratios = mgr.CompressionRatios()
+	}
+	plan := pipeline.BuildPlan(cfg, paths, ratios)
+
+	columns := []table.Column{
+		{Title: "File", Width: 24},
+		{Title: "Output", Width: 30},
+		{Title: "Size", Width: 10},
+		{Title: "Est. After", Width: 10},
+		{Title: "Note", Width: 30},
+	}
+	t := table.New(table.WithColumns(columns), table.WithFocused(true), table.WithHeight(10))
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.BorderStyle(lipgloss.NormalBorder()).BorderBottom(true).Bold(false)
+	t.SetStyles(s)
+
+	var rows []table.Row
+	for _, e := range plan.Entries {
+		est := "?"
+		if e.EstimatedSize >= 0 {
+			est = formatBytes(e.EstimatedSize)
+		}
+		note := ""
+		switch {
+		case e.SkipReason != "":
+			est = "-"
+			note = "skip: " + e.SkipReason
+		case e.Overwrites:
+			note = "overwrites existing file"
+		case e.Output == e.Input:
+			note = "replaces original"
+		}
+		if e.Animated && e.SkipReason == "" {
+			note = strings.TrimPrefix(note+", animated", ", ")
+		}
+		rows = append(rows, table.Row{
+			filepath.Base(e.Input),
+			e.Output,
+			formatBytes(e.Size),
+			est,
+			note,
+		})
+	}
+	t.SetRows(rows)
+
+	return planModel{table: t, plan: plan}
+}
+
+func (m MainModel) updatePlan(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.plan.table, cmd = m.plan.table.Update(msg)
+	return m, cmd
+}
+
+func (m MainModel) viewPlan() string {
+	plan := m.plan.plan
+	if plan == nil || len(plan.Entries) == 0 {
+		return docStyle.Render("Nothing pending in the queue.\n(Esc to go back)")
+	}
+
+	m.plan.table.SetHeight(m.height - 10)
+
+	summary := fmt.Sprintf(" Compress: %d | Skip: %d | Quota: %d | Size: %s ",
+		plan.QuotaCost, len(plan.Skipped()), plan.QuotaCost, formatBytes(plan.TotalSize))
+	if plan.Estimated > 0 {
+		saved, pct := plan.EstimatedSavings()
+		summary += fmt.Sprintf("| Est. saved: %s (%.0f%%) ", formatBytes(saved), pct)
+	} else {
+		summary += "| Est. saved: unknown "
+	}
+	statsView := styleStatusMode.Copy().Background(lipgloss.Color(ColorGreen)).Render(summary)
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Center, styleHeaderPath.Render("Plan (dry run)"), statsView),
+		m.plan.table.View(),
+		styleDim.Render(" Nothing is uploaded. [R] Run | [Esc] Back to Queue"),
+	)
+}
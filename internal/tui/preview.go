@@ -10,6 +10,9 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/gmsakibursabbir/tinitui/internal/imageinfo"
+	"github.com/gmsakibursabbir/tinitui/internal/scanner"
 )
 
 // generatePreview returns a string representation of the file content/metadata
@@ -23,7 +26,6 @@ func generatePreview(path string, width, height int) string {
 		return fmt.Sprintf("\n  📂 Directory: %s\n  Mod: %s", filepath.Base(path), info.ModTime().Format("2006-01-02 15:04"))
 	}
 
-	ext := strings.ToLower(filepath.Ext(path))
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("\n  📄 %s\n", filepath.Base(path)))
@@ -31,7 +33,13 @@ func generatePreview(path string, width, height int) string {
 	sb.WriteString(fmt.Sprintf("  Mod: %s\n", info.ModTime().Format("2006-01-02 15:04")))
 
 	// Image Handling
-	if isImage(ext) {
+	if format, mismatch := scanner.Detect(path); format != "" {
+		if mismatch {
+			sb.WriteString(fmt.Sprintf("  Warning: content is %s\n", strings.ToUpper(format)))
+		}
+		if meta, err := imageinfo.Inspect(path); err == nil && meta.Animated {
+			sb.WriteString("  Animated: yes\n")
+		}
 		file, err := os.Open(path)
 		if err == nil {
 			defer file.Close()
@@ -54,14 +62,6 @@ func generatePreview(path string, width, height int) string {
 	return sb.String()
 }
 
-func isImage(ext string) bool {
-	switch ext {
-	case ".png", ".jpg", ".jpeg", ".webp":
-		return true
-	}
-	return false
-}
-
 // generateSimpleAscii generates a very basic block preview
 // This is a naive implementation; for better quality we'd need a proper resizer.
 func generateSimpleAscii(path string, w, h int) string {
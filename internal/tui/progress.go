@@ -22,6 +22,7 @@ type progressModel struct {
 	// Cache stats
 	total    int
 	completed int
+	hookErr  error // From the on_complete or after_compress hooks, shown in the bottom bar
 }
 
 func newProgressModel() progressModel {
@@ -93,15 +94,19 @@ func (m MainModel) updateProgress(msg tea.Msg) (tea.Model, tea.Cmd) {
 			total := len(jobs)
 			completed := 0
 			for _, j := range jobs {
-				if j.Status == pipeline.StatusDone || j.Status == pipeline.StatusFailed {
+				if j.Status.IsTerminal() {
 					completed++
 				}
+				if j.HookError != nil && m.progress.hookErr == nil {
+					m.progress.hookErr = fmt.Errorf("%s: %w", filepath.Base(j.FilePath), j.HookError)
+				}
 			}
 			m.progress.total = total
 			m.progress.completed = completed
 			
 			if completed == total && total > 0 {
 				m.progress.done = true
+				cmds = append(cmds, runCompleteCmd(m.pipeline))
 				m.state = StateHistory // Auto switch to history? Or just show done.
 				// Let's stay in Compress but show Done.
 			}
@@ -111,6 +116,17 @@ func (m MainModel) updateProgress(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+type runCompleteMsg struct {
+	err error
+}
+
+// runCompleteCmd runs the on_complete hook off the UI goroutine.
+func runCompleteCmd(p *pipeline.Pipeline) tea.Cmd {
+	return func() tea.Msg {
+		return runCompleteMsg{err: p.RunComplete()}
+	}
+}
+
 func waitForPipeline(p *pipeline.Pipeline) tea.Cmd {
 	return func() tea.Msg {
 		job := <-p.Updates()
@@ -160,7 +176,7 @@ func (m MainModel) viewProgress() string {
 			totalOrig += j.OriginalSize
 			totalComp += j.CompressedSize
 			totalSaved += j.SavedBytes
-		} else if j.Status == pipeline.StatusFailed {
+		} else if j.Status == pipeline.StatusFailed || j.Status == pipeline.StatusSkipped {
 			completed++
 		}
 	}
@@ -185,7 +201,7 @@ func (m MainModel) viewProgress() string {
 			formatBytes(totalSaved),
 			percentSaved,
 		)
-		
+
 		return lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
 			BorderForeground(lipgloss.Color("62")).
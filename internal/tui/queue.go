@@ -11,7 +11,10 @@ import (
 )
 
 type queueModel struct {
-	table table.Model
+	table    table.Model
+	scanning bool // A folder scan is still adding files
+	scanned  int
+	found    []string // Scanned files held back until the scan is done
 }
 
 func newQueueModel() queueModel {
@@ -62,12 +65,22 @@ func (m *queueModel) Sync(jobs []*pipeline.Job) {
 			} else {
 				status = "✔ Done"
 			}
+			if j.HookError != nil {
+				status += " (hook failed)"
+			}
 		} else if j.Status == pipeline.StatusFailed {
 			status = "❌ Failed"
+		} else if j.Status == pipeline.StatusSkipped {
+			status = "⏭ Skipped: " + j.SkipReason
+		}
+
+		name := filepath.Base(j.FilePath)
+		if j.Animated {
+			name += " 🎞"
 		}
 
 		rows[i] = table.Row{
-			filepath.Base(j.FilePath),
+			name,
 			status,
 			formatBytes(j.OriginalSize),
 			after,
@@ -98,6 +111,10 @@ func (m MainModel) updateQueue(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "c":
 			m.pipeline.ClearCompleted()
+		case "p":
+			m.plan = newPlanModel(m.config, m.pipeline.Jobs(), m.history.mgr)
+			m.state = StatePlan
+			return m, nil
 		}
 	
 	}
@@ -129,6 +146,9 @@ func (m MainModel) viewQueue() string {
 	if savedBytes > 0 {
 		stats += fmt.Sprintf("| Saved: %s ", formatBytes(savedBytes))
 	}
+	if m.queue.scanning {
+		stats += fmt.Sprintf("| Scanning… %d found ", m.queue.scanned)
+	}
 	statsView := styleStatusMode.Copy().Background(lipgloss.Color(ColorGreen)).Render(stats)
 
 	// Ensure table dimensions
@@ -141,6 +161,6 @@ func (m MainModel) viewQueue() string {
 	return lipgloss.JoinVertical(lipgloss.Left, 
 		lipgloss.JoinHorizontal(lipgloss.Center, styleHeaderPath.Render("Queue"), statsView),
 		tView,
-		styleDim.Render(" [R] Run | [D] Delete | [C] Clear Completed"),
+		styleDim.Render(" [R] Run | [P] Plan | [D] Delete | [C] Clear Completed"),
 	)
 }
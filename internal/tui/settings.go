@@ -14,7 +14,7 @@ import (
 
 type settingsModel struct {
 	cursor  int
-	inputs  []textinput.Model // For API Key
+	inputs  []textinput.Model // API Key, output directory
 	editing bool              // Are we editing a text input?
 	
 	// Update state
@@ -32,9 +32,13 @@ func newSettingsModel() settingsModel {
 	ti.EchoMode = textinput.EchoPassword
 	ti.Width = 30
 
+	dir := textinput.New()
+	dir.Placeholder = "Output directory"
+	dir.Width = 30
+
 	return settingsModel{
 		cursor: 0,
-		inputs: []textinput.Model{ti},
+		inputs: []textinput.Model{ti, dir},
 	}
 }
 
@@ -82,15 +86,28 @@ func (m MainModel) updateSettings(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if m.settings.editing {
 		// ... (Same input handling)
 		var cmd tea.Cmd
-		if m.settings.cursor == 0 {
+		switch m.settings.cursor {
+		case 0:
 			m.settings.inputs[0], cmd = m.settings.inputs[0].Update(msg)
+		case 3:
+			m.settings.inputs[1], cmd = m.settings.inputs[1].Update(msg)
 		}
 		
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			if msg.Type == tea.KeyEnter || msg.Type == tea.KeyEsc {
 				m.settings.editing = false
-				m.config.APIKey = m.settings.inputs[0].Value()
+				if m.settings.cursor == 3 {
+					// Directory mode needs a directory; without one it stays off
+					dir := strings.TrimSpace(m.settings.inputs[1].Value())
+					if msg.Type == tea.KeyEsc || dir == "" {
+						return m, nil
+					}
+					m.config.OutputMode = "directory"
+					m.config.OutputDir = dir
+				} else {
+					m.config.APIKey = m.settings.inputs[0].Value()
+				}
 				m.config.Save()
 				return m, nil
 			}
@@ -104,11 +121,11 @@ func (m MainModel) updateSettings(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "up", "k":
 			m.settings.cursor--
 			if m.settings.cursor < 0 {
-				m.settings.cursor = 7
+				m.settings.cursor = 8
 			}
 		case "down", "j":
 			m.settings.cursor++
-			if m.settings.cursor > 7 {
+			if m.settings.cursor > 8 {
 				m.settings.cursor = 0
 			}
 		case "enter", " ":
@@ -139,10 +156,13 @@ func (m MainModel) updateSettings(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.config.Mascot = config.MascotOn
 			case 3: // Output Mode
 				if m.config.OutputMode == "replace" {
-					m.config.OutputMode = "directory"
-				} else {
-					m.config.OutputMode = "replace"
+					// Ask for the directory before switching
+					m.settings.editing = true
+					m.settings.inputs[1].SetValue(m.config.OutputDir)
+					m.settings.inputs[1].Focus()
+					return m, nil
 				}
+				m.config.OutputMode = "replace"
 			case 4: // Metadata
 				m.config.Metadata = !m.config.Metadata
 			case 5: // Overwrite Original
@@ -151,7 +171,20 @@ func (m MainModel) updateSettings(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else {
 					m.config.Suffix = "" // Enable overwrite
 				}
-			case 6: // Update
+			case 6: // Profile
+				// Cycle through the profiles, then back to none
+				names := append(m.config.ProfileNames(), "")
+				next := names[0]
+				for i, name := range names {
+					if name == m.config.Profile {
+						next = names[(i+1)%len(names)]
+						break
+					}
+				}
+				if err := m.config.SelectProfile(next); err == nil && m.pipeline != nil {
+					m.pipeline.Configure(m.config.Concurrency)
+				}
+			case 7: // Update
 				if m.settings.updateAvailable && m.settings.release != nil {
 					// Install
 					m.settings.updateStatus = "Downloading & Installing..."
@@ -163,7 +196,7 @@ func (m MainModel) updateSettings(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.settings.updateStatus = "Checking..."
 					return m, checkUpdateCmd()
 				}
-			case 7: // Back
+			case 8: // Back
 				m.state = StateBrowser
 			}
 			m.config.Save()
@@ -235,7 +268,13 @@ func (m MainModel) viewSettings() string {
 	renderItem(2, "Mascot Type", m.config.MascotType)
 
 	// 3 Output Mode
-	renderItem(3, "Output Mode", m.config.OutputMode)
+	outputVal := m.config.OutputMode
+	if m.settings.editing && m.settings.cursor == 3 {
+		outputVal = "directory " + m.settings.inputs[1].View()
+	} else if m.config.OutputMode == "directory" {
+		outputVal += " (" + m.config.OutputDir + ")"
+	}
+	renderItem(3, "Output Mode", outputVal)
 
 	// 4 Metadata
 	metaVal := "OFF"
@@ -247,15 +286,25 @@ func (m MainModel) viewSettings() string {
 	if m.config.Suffix == "" { overVal = "YES (Danger!)" }
 	renderItem(5, "Overwrite Original", overVal)
 
-	// 6 Update
+	// 6 Profile
+	profileVal := "(none)"
+	if m.config.Profile != "" {
+		profileVal = m.config.Profile
+	}
+	if len(m.config.Profiles) == 0 {
+		profileVal += " - add \"profiles\" to the config"
+	}
+	renderItem(6, "Profile", profileVal)
+
+	// 7 Update
 	updateVal := "Check for Updates"
 	if m.settings.updateStatus != "" {
 		updateVal = m.settings.updateStatus
 	}
-	renderItem(6, "Software Update", updateVal)
+	renderItem(7, "Software Update", updateVal)
 
-	// 7 Back
-	renderItem(7, "Back", "")
+	// 8 Back
+	renderItem(8, "Back", "")
 
 	help := "(Space/Enter to change)"
 	if m.settings.editing {
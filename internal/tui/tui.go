@@ -10,8 +10,16 @@ import (
 
 // Start initializes and runs the Bubble Tea program
 func Start(cfg *config.Config) {
-	p := tea.NewProgram(InitialModel(cfg))
-	if _, err := p.Run(); err != nil {
+	m := InitialModel(cfg)
+	p := tea.NewProgram(m)
+	_, err := p.Run()
+	// Records of a run interrupted by quitting are still kept
+	if m.history.mgr != nil {
+		if err := m.history.mgr.Close(); err != nil {
+			fmt.Printf("Error saving history: %v\n", err)
+		}
+	}
+	if err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
 	}
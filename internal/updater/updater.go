@@ -106,7 +106,7 @@ func Update(release *Release) error {
 	defer resp.Body.Close()
 
 	// Temp file
-	tmpFile, err := os.CreateTemp("", "tinytui-update-*")
+	tmpFile, err := os.CreateTemp("", "tinitui-update-*")
 	if err != nil {
 		return err
 	}